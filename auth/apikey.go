@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/posener/grpcgw/middleware"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultAPIKeyHeader is the default header of API keys.
+	DefaultAPIKeyHeader = "X-Api-Key"
+	// DefaultAPIKeyParam is the default query parameter of API keys.
+	DefaultAPIKeyParam = "api_key"
+
+	// apiKeysReloadInterval is the interval in which the key file is
+	// checked for changes.
+	apiKeysReloadInterval = 10 * time.Second
+)
+
+// APIKey is the name and scopes of an API key.
+type APIKey struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes,omitempty"`
+	// SHA256 is the hex encoded SHA-256 hash of the key.
+	SHA256 string `json:"sha256"`
+}
+
+// APIKeysConfig configures API key authentication.
+type APIKeysConfig struct {
+	// KeyFile is a JSON file of hashed keys:
+	// {"keys": [{"name": "partner", "scopes": ["read"], "sha256": "<hex>"}]}.
+	KeyFile string
+	// Header is the header of API keys of REST calls. Native gRPC callers
+	// send the key in the metadata key of the same name, in lower case.
+	// The default is DefaultAPIKeyHeader.
+	Header string
+	// Param is the query parameter of API keys of REST calls. The default
+	// is DefaultAPIKeyParam.
+	Param string
+}
+
+// APIKeys is an Authenticator of API keys. The key file is reloaded when
+// it changes, or when the process gets SIGHUP.
+type APIKeys struct {
+	file   string
+	header string
+	param  string
+
+	mu      sync.RWMutex
+	keys    map[string]*APIKey
+	modTime time.Time
+}
+
+// NewAPIKeys loads the keys of an API keys config.
+func NewAPIKeys(c APIKeysConfig) (*APIKeys, error) {
+	a := &APIKeys{file: c.KeyFile, header: c.Header, param: c.Param}
+	if a.header == "" {
+		a.header = DefaultAPIKeyHeader
+	}
+	if a.param == "" {
+		a.param = DefaultAPIKeyParam
+	}
+	if err := a.reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// reload loads the key file, and replaces the current keys if it is valid.
+func (a *APIKeys) reload() error {
+	info, err := os.Stat(a.file)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(a.file)
+	if err != nil {
+		return err
+	}
+	var file struct {
+		Keys []*APIKey `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return fmt.Errorf("parsing %s: %s", a.file, err)
+	}
+	keys := make(map[string]*APIKey, len(file.Keys))
+	for _, key := range file.Keys {
+		if key.Name == "" || len(key.SHA256) != sha256.Size*2 {
+			return fmt.Errorf("parsing %s: key %q must have a name and a hex SHA-256 hash", a.file, key.Name)
+		}
+		keys[strings.ToLower(key.SHA256)] = key
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.keys = keys
+	a.modTime = info.ModTime()
+	return nil
+}
+
+// changed reports whether the key file changed since the last successful
+// reload.
+func (a *APIKeys) changed() bool {
+	info, err := os.Stat(a.file)
+	if err != nil {
+		return false
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return !info.ModTime().Equal(a.modTime)
+}
+
+// Watch reloads the key file when it changes or when the process gets
+// SIGHUP, until ctx is done. If the new file is invalid, the old keys are
+// kept.
+func (a *APIKeys) Watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(apiKeysReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-ticker.C:
+			if !a.changed() {
+				continue
+			}
+		}
+		if err := a.reload(); err != nil {
+			log.Printf("Failed reloading API keys, keeping the old ones: %s", err)
+			continue
+		}
+		log.Printf("Reloaded API keys %s", a.file)
+	}
+}
+
+// lookup returns the API key of a raw key.
+func (a *APIKeys) lookup(raw string) (*APIKey, bool) {
+	sum := sha256.Sum256([]byte(raw))
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	key, ok := a.keys[hex.EncodeToString(sum[:])]
+	return key, ok
+}
+
+// fromRequest returns the raw API key of a REST call.
+func (a *APIKeys) fromRequest(r *http.Request) string {
+	if raw := r.Header.Get(a.header); raw != "" {
+		return raw
+	}
+	return r.URL.Query().Get(a.param)
+}
+
+// Metadata forwards the API key of a REST call to the gRPC server. It
+// should be installed on the gateway mux with runtime.WithMetadata.
+func (a *APIKeys) Metadata(ctx context.Context, r *http.Request) metadata.MD {
+	if raw := a.fromRequest(r); raw != "" {
+		return metadata.Pairs(a.header, raw)
+	}
+	return nil
+}
+
+// Middleware puts the API key of REST callers into the request context,
+// and adds its name to the access log. Calls with invalid keys are
+// rejected by the gRPC interceptors.
+func (a *APIKeys) Middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := a.lookup(a.fromRequest(r)); ok {
+			middleware.SetLogField(r.Context(), "key", key.Name)
+			r = r.WithContext(NewAPIKeyContext(r.Context(), key))
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Authenticate validates the API key of a gRPC call, and returns a
+// context carrying it. The key name is added to the access log of native
+// gRPC calls that are served by the REST listener.
+func (a *APIKeys) Authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(a.header)
+	if len(values) == 0 {
+		return nil, ErrNoCredentials
+	}
+	key, ok := a.lookup(values[0])
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	middleware.SetLogField(ctx, "key", key.Name)
+	return NewAPIKeyContext(ctx, key), nil
+}
+
+type apiKeyKey struct{}
+
+// NewAPIKeyContext returns a context carrying the caller's API key.
+func NewAPIKeyContext(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, key)
+}
+
+// APIKeyFromContext returns the caller's API key, if the caller was
+// authenticated with one.
+func APIKeyFromContext(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(apiKeyKey{}).(*APIKey)
+	return key, ok && key != nil
+}
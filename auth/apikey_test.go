@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestNewAPIKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		raw      string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "valid", content: `{"keys": [{"name": "a", "sha256": "` + hash("secret-a") + `"}]}`, raw: "secret-a", wantName: "a"},
+		{name: "upper case hash", content: `{"keys": [{"name": "a", "sha256": "` + strings.ToUpper(hash("secret-a")) + `"}]}`, raw: "secret-a", wantName: "a"},
+		{name: "unknown key", content: `{"keys": [{"name": "a", "sha256": "` + hash("secret-a") + `"}]}`, raw: "secret-b"},
+		{name: "invalid json", content: `{"keys": [`, wantErr: true},
+		{name: "no name", content: `{"keys": [{"sha256": "` + hash("secret-a") + `"}]}`, wantErr: true},
+		{name: "raw key instead of hash", content: `{"keys": [{"name": "a", "sha256": "secret-a"}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAPIKeys(APIKeysConfig{KeyFile: writeFile(t, "keys.json", []byte(tt.content))})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAPIKeys() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			key, ok := a.lookup(tt.raw)
+			if ok != (tt.wantName != "") || ok && key.Name != tt.wantName {
+				t.Errorf("lookup(%q) = %v, %v, want %q", tt.raw, key, ok, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestAPIKeysReload(t *testing.T) {
+	path := writeFile(t, "keys.json", []byte(`{"keys": [{"name": "a", "sha256": "`+hash("secret-a")+`"}]}`))
+	a, err := NewAPIKeys(APIKeysConfig{KeyFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Modification times are set explicitly, since writes in the same
+	// clock tick may not change them.
+	modTime := time.Now()
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		modTime = modTime.Add(time.Second)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+		want    map[string]bool
+	}{
+		{
+			name:    "replaced key",
+			content: `{"keys": [{"name": "b", "sha256": "` + hash("secret-b") + `"}]}`,
+			want:    map[string]bool{"secret-a": false, "secret-b": true},
+		},
+		{
+			name:    "invalid file keeps the old keys",
+			content: `{"keys": [{"name": "", "sha256": "` + hash("secret-c") + `"}]}`,
+			wantErr: true,
+			want:    map[string]bool{"secret-b": true, "secret-c": false},
+		},
+		{
+			name:    "added key",
+			content: `{"keys": [{"name": "b", "sha256": "` + hash("secret-b") + `"}, {"name": "c", "sha256": "` + hash("secret-c") + `"}]}`,
+			want:    map[string]bool{"secret-b": true, "secret-c": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write(tt.content)
+			if !a.changed() {
+				t.Fatal("changed() = false after writing the key file")
+			}
+			if err := a.reload(); (err != nil) != tt.wantErr {
+				t.Fatalf("reload() error = %v, want error %v", err, tt.wantErr)
+			}
+			if changed := a.changed(); changed != tt.wantErr {
+				t.Errorf("changed() = %v after reload, want %v", changed, tt.wantErr)
+			}
+			for raw, want := range tt.want {
+				if _, ok := a.lookup(raw); ok != want {
+					t.Errorf("lookup(%q) = %v, want %v", raw, ok, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/posener/grpcgw/middleware"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNoCredentials is returned by an Authenticator for calls that carry
+// none of its credentials.
+var ErrNoCredentials = status.Error(codes.Unauthenticated, "missing credentials")
+
+// DefaultPublicMethods are the methods that are open to unauthenticated
+// callers when no public methods are configured.
+var DefaultPublicMethods = []string{"/grpc.health.v1.Health/*"}
+
+// Authenticator authenticates gRPC calls with one kind of credentials.
+type Authenticator interface {
+	// Authenticate returns a context carrying the caller's validated
+	// credentials. It returns ErrNoCredentials if the call carries none,
+	// and an Unauthenticated error if they are invalid.
+	Authenticate(ctx context.Context) (context.Context, error)
+}
+
+// Authenticated reports whether a caller was authenticated by a JWT or an
+// API key.
+func Authenticated(ctx context.Context) bool {
+	_, claims := ClaimsFromContext(ctx)
+	_, key := APIKeyFromContext(ctx)
+	return claims || key
+}
+
+// Certificates is an Authenticator of verified client certificates. The
+// identity of the certificate must already be in the context, as the
+// grpcgw server puts it there.
+type Certificates struct{}
+
+// Authenticate returns ErrNoCredentials if the caller did not present a
+// verified client certificate.
+func (Certificates) Authenticate(ctx context.Context) (context.Context, error) {
+	if _, ok := FromContext(ctx); !ok {
+		return nil, ErrNoCredentials
+	}
+	return ctx, nil
+}
+
+// authenticate authenticates a gRPC call with every authenticator whose
+// credentials it carries. A call must carry valid credentials of at
+// least one authenticator, and no invalid credentials, unless its method
+// is public.
+func authenticate(ctx context.Context, method string, public []string, authenticators []Authenticator) (context.Context, error) {
+	if matchMethod(public, method) {
+		return ctx, nil
+	}
+	authenticated := false
+	for _, a := range authenticators {
+		next, err := a.Authenticate(ctx)
+		if err == ErrNoCredentials {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ctx, authenticated = next, true
+	}
+	if !authenticated {
+		return nil, ErrNoCredentials
+	}
+	return ctx, nil
+}
+
+// UnaryServerInterceptor authenticates unary gRPC calls. If public is
+// nil, DefaultPublicMethods are public.
+func UnaryServerInterceptor(public []string, authenticators ...Authenticator) grpc.UnaryServerInterceptor {
+	if public == nil {
+		public = DefaultPublicMethods
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, info.FullMethod, public, authenticators)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authenticates streaming gRPC calls. If public
+// is nil, DefaultPublicMethods are public.
+func StreamServerInterceptor(public []string, authenticators ...Authenticator) grpc.StreamServerInterceptor {
+	if public == nil {
+		public = DefaultPublicMethods
+	}
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), info.FullMethod, public, authenticators)
+		if err != nil {
+			return err
+		}
+		return handler(srv, middleware.WrapServerStream(stream, ctx))
+	}
+}
+
+// matchMethod reports whether a full method name matches one of the
+// patterns. A pattern ending with "*" matches a prefix.
+func matchMethod(patterns []string, method string) bool {
+	for _, pattern := range patterns {
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			if strings.HasPrefix(method, prefix) {
+				return true
+			}
+		} else if method == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticate(t *testing.T) {
+	certCtx := NewContext(context.Background(), &Identity{CommonName: "client.example.com"})
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		method         string
+		authenticators []Authenticator
+		want           codes.Code
+	}{
+		{name: "public", ctx: context.Background(), method: "/grpc.health.v1.Health/Check", want: codes.OK},
+		{name: "no credentials", ctx: context.Background(), method: "/pkg.Service/Get", want: codes.Unauthenticated},
+		{name: "certificate without opt-in", ctx: certCtx, method: "/pkg.Service/Get", want: codes.Unauthenticated},
+		{name: "certificate", ctx: certCtx, method: "/pkg.Service/Get", authenticators: []Authenticator{Certificates{}}, want: codes.OK},
+		{name: "certificates without certificate", ctx: context.Background(), method: "/pkg.Service/Get", authenticators: []Authenticator{Certificates{}}, want: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := authenticate(tt.ctx, tt.method, DefaultPublicMethods, tt.authenticators)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/posener/grpcgw/middleware"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// reservedPrefix is the prefix of the metadata keys set by the gateway.
+	reservedPrefix = "grpcgw-"
+	// gatewayTokenKey is the metadata key proving that a call was made by
+	// the server's own REST gateway.
+	gatewayTokenKey = "grpcgw-gateway-token"
+	// identityMetadataKey is the metadata key of the identity of the REST caller.
+	identityMetadataKey = "grpcgw-identity"
+)
+
+// Gateway forwards the identities of REST callers through the REST gateway
+// to the gRPC server, and puts the identities of gRPC callers into the
+// context of the gRPC methods.
+//
+// Forwarded identities are trusted only if they carry a token that is
+// known only to the server, so native gRPC callers can't forge them.
+type Gateway struct {
+	token string
+}
+
+// NewGateway returns a gateway with a random token.
+func NewGateway() *Gateway {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Panicf("Failed generating gateway token: %s", err)
+	}
+	return &Gateway{token: hex.EncodeToString(b)}
+}
+
+// outgoing returns the context of a gRPC call of the REST gateway, with
+// the metadata that forwards the identity of the REST caller. Reserved
+// metadata that the gateway forwarded from HTTP headers is dropped first,
+// so REST callers can't forge it whatever the gateway's header matcher.
+func (g *Gateway) outgoing(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	for key := range md {
+		if Reserved(key) {
+			delete(md, key)
+		}
+	}
+	md.Set(gatewayTokenKey, g.token)
+	if id, ok := FromContext(ctx); ok {
+		encoded, err := json.Marshal(id)
+		if err != nil {
+			middleware.Logf(ctx, "Failed encoding identity: %s", err)
+		} else {
+			md.Set(identityMetadataKey, string(encoded))
+		}
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// UnaryClientInterceptor forwards the identity of REST callers on unary
+// calls of the REST gateway. The gateway's calls carry the context of the
+// REST request, with the caller's identity.
+func (g *Gateway) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(g.outgoing(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor forwards the identity of REST callers on
+// streaming calls of the REST gateway.
+func (g *Gateway) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(g.outgoing(ctx), desc, cc, method, opts...)
+	}
+}
+
+// Reserved reports whether a metadata key is reserved for the gateway.
+// The gateway must not forward HTTP headers into reserved keys, so REST
+// callers can't forge forwarded identities.
+func Reserved(key string) bool {
+	return strings.HasPrefix(strings.ToLower(key), reservedPrefix)
+}
+
+// FromGateway reports whether a gRPC call was made by the server's REST
+// gateway.
+func (g *Gateway) FromGateway(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	tokens := md.Get(gatewayTokenKey)
+	return len(tokens) == 1 && subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(g.token)) == 1
+}
+
+// identity returns the identity of a gRPC caller. For calls made by the
+// REST gateway, it is the forwarded identity of the REST caller.
+func (g *Gateway) identity(ctx context.Context) *Identity {
+	if !g.FromGateway(ctx) {
+		return peerIdentity(ctx)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(identityMetadataKey)
+	if len(values) == 0 {
+		return nil
+	}
+	var id Identity
+	if err := json.Unmarshal([]byte(values[0]), &id); err != nil {
+		middleware.Logf(ctx, "Failed decoding forwarded identity: %s", err)
+		return nil
+	}
+	return &id
+}
+
+func (g *Gateway) newContext(ctx context.Context) context.Context {
+	if id := g.identity(ctx); id != nil {
+		return NewContext(ctx, id)
+	}
+	return ctx
+}
+
+// UnaryServerInterceptor puts the caller's identity into the context of
+// unary gRPC methods.
+func (g *Gateway) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(g.newContext(ctx), req)
+	}
+}
+
+// StreamServerInterceptor puts the caller's identity into the context of
+// streaming gRPC methods.
+func (g *Gateway) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, middleware.WrapServerStream(stream, g.newContext(stream.Context())))
+	}
+}
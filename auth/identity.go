@@ -0,0 +1,118 @@
+// Package auth holds the identity of authenticated callers of a grpcgw
+// server, for both REST and gRPC calls.
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+const spiffeScheme = "spiffe://"
+
+// Identity is the identity of an authenticated caller.
+type Identity struct {
+	// CommonName is the subject common name of the caller's verified
+	// client certificate.
+	CommonName string `json:"cn,omitempty"`
+	// DNSNames, EmailAddresses and URIs are the subject alternative names
+	// of the caller's verified client certificate.
+	DNSNames       []string `json:"dns,omitempty"`
+	EmailAddresses []string `json:"email,omitempty"`
+	URIs           []string `json:"uri,omitempty"`
+	// SPIFFEID is the first spiffe:// URI of the caller's verified client
+	// certificate.
+	SPIFFEID string `json:"spiffe,omitempty"`
+}
+
+// Names returns the names that identify the caller.
+func (id *Identity) Names() []string {
+	var names []string
+	if id.CommonName != "" {
+		names = append(names, id.CommonName)
+	}
+	names = append(names, id.DNSNames...)
+	names = append(names, id.EmailAddresses...)
+	names = append(names, id.URIs...)
+	return names
+}
+
+// MatchesAny reports whether one of the caller's names is in names.
+func (id *Identity) MatchesAny(names []string) bool {
+	for _, mine := range id.Names() {
+		for _, name := range names {
+			if mine == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type identityKey struct{}
+
+// NewContext returns a context carrying the caller's identity.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the caller's identity, if the caller was
+// authenticated.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok && id != nil
+}
+
+// CertificateIdentity returns the identity of the peer of a TLS connection,
+// or nil if the peer did not present a verified certificate.
+func CertificateIdentity(state *tls.ConnectionState) *Identity {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return certificateIdentity(state.VerifiedChains[0][0])
+}
+
+func certificateIdentity(cert *x509.Certificate) *Identity {
+	id := &Identity{
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+	}
+	for _, uri := range cert.URIs {
+		id.URIs = append(id.URIs, uri.String())
+		if id.SPIFFEID == "" && strings.HasPrefix(uri.String(), spiffeScheme) {
+			id.SPIFFEID = uri.String()
+		}
+	}
+	return id
+}
+
+// CertificateMiddleware puts the identity of REST callers that presented
+// a verified client certificate into the request context.
+func CertificateMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := CertificateIdentity(r.TLS); id != nil {
+			r = r.WithContext(NewContext(r.Context(), id))
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// peerIdentity returns the identity of a gRPC caller that presented a
+// verified client certificate.
+func peerIdentity(ctx context.Context) *Identity {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	return CertificateIdentity(&info.State)
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authorizationKey is the metadata key of bearer tokens. The REST gateway
+// forwards the Authorization header of REST calls under this key.
+const authorizationKey = "authorization"
+
+// JWTConfig configures the validation of JWT bearer tokens.
+type JWTConfig struct {
+	// KeyFile is a JWKS file, or a PEM file of public keys and
+	// certificates. HS256 keys are "oct" keys of a JWKS file.
+	KeyFile string
+	// Issuer and Audience, if set, must match the "iss" and "aud" claims.
+	Issuer   string
+	Audience string
+	// ClockSkew is the allowed difference between the clocks of the
+	// server and the token issuer.
+	ClockSkew time.Duration
+}
+
+// JWT is an Authenticator of JWT bearer tokens, signed with HS256, RS256
+// or ES256 and carrying an "exp" claim. REST callers are authenticated the
+// same way, since the REST gateway forwards their Authorization header.
+type JWT struct {
+	keys   []verificationKey
+	parser *jwt.Parser
+}
+
+// NewJWT loads the keys of a JWT config.
+func NewJWT(c JWTConfig) (*JWT, error) {
+	keys, err := loadVerificationKeys(c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "RS256", "ES256"}),
+		jwt.WithLeeway(c.ClockSkew),
+		jwt.WithExpirationRequired(),
+	}
+	if c.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(c.Issuer))
+	}
+	if c.Audience != "" {
+		opts = append(opts, jwt.WithAudience(c.Audience))
+	}
+	return &JWT{keys: keys, parser: jwt.NewParser(opts...)}, nil
+}
+
+// Claims are the claims of a validated JWT.
+type Claims map[string]interface{}
+
+// Subject returns the "sub" claim.
+func (c Claims) Subject() string {
+	sub, _ := c["sub"].(string)
+	return sub
+}
+
+// Strings returns a claim that is a string or a list of strings, such as
+// "roles" or "scope". A space separated string is split.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+type claimsKey struct{}
+
+// NewClaimsContext returns a context carrying the claims of a validated
+// JWT.
+func NewClaimsContext(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims of the caller's JWT, if the caller
+// was authenticated with one.
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+// validate validates a bearer token and returns its claims.
+func (j *JWT) validate(token string) (Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := j.parser.ParseWithClaims(token, claims, j.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	return Claims(claims), nil
+}
+
+// keyFunc returns the keys that may verify a token: the key with the
+// token's key ID, or all the keys of the token's algorithm.
+func (j *JWT) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	var set jwt.VerificationKeySet
+	for _, k := range j.keys {
+		if k.alg == token.Method.Alg() && (kid == "" || k.id == "" || k.id == kid) {
+			set.Keys = append(set.Keys, k.key)
+		}
+	}
+	if len(set.Keys) == 0 {
+		return nil, errors.New("no matching key")
+	}
+	return set, nil
+}
+
+// Authenticate validates the bearer token of a gRPC call, and returns a
+// context carrying its claims.
+func (j *JWT) Authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationKey)
+	if len(values) == 0 {
+		return nil, ErrNoCredentials
+	}
+	token := values[0]
+	if len(token) < len("Bearer ") || !strings.EqualFold(token[:len("Bearer ")], "Bearer ") {
+		return nil, unauthenticated(ctx, "authorization is not a bearer token")
+	}
+	claims, err := j.validate(strings.TrimSpace(token[len("Bearer "):]))
+	if err != nil {
+		return nil, unauthenticated(ctx, "invalid bearer token: "+err.Error())
+	}
+	return NewClaimsContext(ctx, claims), nil
+}
+
+// unauthenticated returns an Unauthenticated error, and asks the caller
+// for a bearer token.
+func unauthenticated(ctx context.Context, msg string) error {
+	grpc.SetHeader(ctx, metadata.Pairs("www-authenticate", "Bearer"))
+	return status.Error(codes.Unauthenticated, msg)
+}
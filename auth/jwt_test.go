@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testKeys are the signing keys of test tokens.
+type testKeys struct {
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	secret []byte
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKeys{rsa: rsaKey, ec: ecKey, secret: []byte("0123456789abcdef0123456789abcdef")}
+}
+
+// jwks returns the JWKS of the public keys.
+func (k testKeys) jwks() []byte {
+	enc := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	b, _ := json.Marshal(map[string][]jwk{"keys": {
+		{Kty: "RSA", Kid: "rsa", N: enc(k.rsa.N), E: enc(big.NewInt(int64(k.rsa.E)))},
+		{Kty: "EC", Kid: "ec", Crv: "P-256", X: enc(k.ec.X), Y: enc(k.ec.Y)},
+		{Kty: "oct", Kid: "hs", K: base64.RawURLEncoding.EncodeToString(k.secret)},
+	}})
+	return b
+}
+
+// rsaPublicPEM returns the PEM encoded RSA public key.
+func (k testKeys) rsaPublicPEM() []byte {
+	der, _ := x509.MarshalPKIXPublicKey(&k.rsa.PublicKey)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func writeFile(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWTAlgorithm(t *testing.T) {
+	keys := newTestKeys(t)
+	j, err := NewJWT(JWTConfig{KeyFile: writeFile(t, "keys.json", keys.jwks())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+	otherRSA, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "RS256", token: sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims)},
+		{name: "ES256", token: sign(t, jwt.SigningMethodES256, "ec", keys.ec, claims)},
+		{name: "HS256", token: sign(t, jwt.SigningMethodHS256, "hs", keys.secret, claims)},
+		{name: "no kid", token: sign(t, jwt.SigningMethodES256, "", keys.ec, claims)},
+		{name: "kid of another key type", token: sign(t, jwt.SigningMethodRS256, "ec", keys.rsa, claims), wantErr: true},
+		{name: "HS256 with the RSA public key", token: sign(t, jwt.SigningMethodHS256, "rsa", keys.rsaPublicPEM(), claims), wantErr: true},
+		{name: "HS256 with the RSA public key without kid", token: sign(t, jwt.SigningMethodHS256, "", keys.rsaPublicPEM(), claims), wantErr: true},
+		{name: "unknown RSA key", token: sign(t, jwt.SigningMethodRS256, "rsa", otherRSA, claims), wantErr: true},
+		{name: "RS384", token: sign(t, jwt.SigningMethodRS384, "rsa", keys.rsa, claims), wantErr: true},
+		{name: "none", token: sign(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, claims), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := j.validate(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && got.Subject() != "alice" {
+				t.Errorf("subject = %q, want alice", got.Subject())
+			}
+		})
+	}
+}
+
+func TestJWTClaims(t *testing.T) {
+	keys := newTestKeys(t)
+	j, err := NewJWT(JWTConfig{
+		KeyFile:   writeFile(t, "keys.json", keys.jwks()),
+		Issuer:    "https://issuer.example.com",
+		Audience:  "grpcgw",
+		ClockSkew: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	valid := func(changes jwt.MapClaims) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"iss": "https://issuer.example.com",
+			"aud": "grpcgw",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{name: "valid", claims: valid(nil)},
+		{name: "expired", claims: valid(jwt.MapClaims{"exp": now.Add(-time.Hour).Unix()}), wantErr: true},
+		{name: "expired within clock skew", claims: valid(jwt.MapClaims{"exp": now.Add(-30 * time.Second).Unix()})},
+		{name: "no exp", claims: valid(jwt.MapClaims{"exp": nil}), wantErr: true},
+		{name: "not yet valid", claims: valid(jwt.MapClaims{"nbf": now.Add(time.Hour).Unix()}), wantErr: true},
+		{name: "not yet valid within clock skew", claims: valid(jwt.MapClaims{"nbf": now.Add(30 * time.Second).Unix()})},
+		{name: "wrong issuer", claims: valid(jwt.MapClaims{"iss": "https://other.example.com"}), wantErr: true},
+		{name: "no issuer", claims: valid(jwt.MapClaims{"iss": nil}), wantErr: true},
+		{name: "wrong audience", claims: valid(jwt.MapClaims{"aud": "other"}), wantErr: true},
+		{name: "audience list", claims: valid(jwt.MapClaims{"aud": []string{"other", "grpcgw"}})},
+		{name: "no audience", claims: valid(jwt.MapClaims{"aud": nil}), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := j.validate(sign(t, jwt.SigningMethodES256, "ec", keys.ec, tt.claims))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadVerificationKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	tests := []struct {
+		name     string
+		content  string
+		wantAlgs []string
+		wantErr  bool
+	}{
+		{name: "jwks", content: string(keys.jwks()), wantAlgs: []string{"RS256", "ES256", "HS256"}},
+		{name: "pem", content: string(keys.rsaPublicPEM()), wantAlgs: []string{"RS256"}},
+		{name: "encryption keys are skipped", content: `{"keys": [{"kty": "oct", "use": "enc", "k": "c2VjcmV0"}, {"kty": "oct", "k": "c2VjcmV0"}]}`, wantAlgs: []string{"HS256"}},
+		{name: "algorithm of another key type", content: `{"keys": [{"kty": "oct", "alg": "RS256", "k": "c2VjcmV0"}]}`, wantErr: true},
+		{name: "unsupported curve", content: `{"keys": [{"kty": "EC", "crv": "P-384", "x": "AQ", "y": "AQ"}]}`, wantErr: true},
+		{name: "unsupported key type", content: `{"keys": [{"kty": "OKP"}]}`, wantErr: true},
+		{name: "no keys", content: `{"keys": []}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadVerificationKeys(writeFile(t, "keys", []byte(tt.content)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadVerificationKeys() error = %v, want error %v", err, tt.wantErr)
+			}
+			var algs []string
+			for _, k := range got {
+				algs = append(algs, k.alg)
+			}
+			if strings.Join(algs, ",") != strings.Join(tt.wantAlgs, ",") {
+				t.Errorf("algorithms = %v, want %v", algs, tt.wantAlgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// verificationKey is a key that verifies JWT signatures.
+type verificationKey struct {
+	// id is the key ID, matched against the "kid" header of tokens. It
+	// is empty for keys loaded from PEM files.
+	id string
+	// alg is the signing algorithm of the key: HS256, RS256 or ES256.
+	alg string
+	key interface{}
+}
+
+// jwk is a JSON web key of a JWKS file.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// Symmetric keys.
+	K string `json:"k"`
+}
+
+// loadVerificationKeys loads the keys of a JWKS file, or of a PEM file
+// of public keys and certificates.
+func loadVerificationKeys(path string) ([]verificationKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []verificationKey
+	if strings.HasPrefix(strings.TrimSpace(string(b)), "{") {
+		keys, err = parseJWKS(b)
+	} else {
+		keys, err = parsePEMKeys(b)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading keys %s: %s", path, err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("loading keys %s: no keys found", path)
+	}
+	return keys, nil
+}
+
+func parseJWKS(b []byte) ([]verificationKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, err
+	}
+	var keys []verificationKey
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.verificationKey()
+		if err == nil && k.Alg != "" && k.Alg != key.alg {
+			err = fmt.Errorf("unsupported algorithm %q", k.Alg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %s", k.Kid, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (k jwk) verificationKey() (verificationKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return verificationKey{}, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return verificationKey{}, err
+		}
+		return verificationKey{id: k.Kid, alg: "RS256", key: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return verificationKey{}, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return verificationKey{}, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return verificationKey{}, err
+		}
+		return verificationKey{id: k.Kid, alg: "ES256", key: &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.K, "="))
+		if err != nil {
+			return verificationKey{}, err
+		}
+		return verificationKey{id: k.Kid, alg: "HS256", key: secret}, nil
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("missing key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func parsePEMKeys(b []byte) ([]verificationKey, error) {
+	var keys []verificationKey
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return keys, nil
+		}
+		var (
+			pub interface{}
+			err error
+		)
+		switch block.Type {
+		case "PUBLIC KEY":
+			pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			cert, err = x509.ParseCertificate(block.Bytes)
+			if err == nil {
+				pub = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch pub := pub.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, verificationKey{alg: "RS256", key: pub})
+		case *ecdsa.PublicKey:
+			keys = append(keys, verificationKey{alg: "ES256", key: pub})
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", pub)
+		}
+	}
+}
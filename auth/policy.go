@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/posener/grpcgw/middleware"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRoleClaim is the JWT claim that lists the caller's roles.
+const DefaultRoleClaim = "roles"
+
+// Policy authorizes gRPC calls by role. Every role is allowed a set of
+// methods, and is bound to principals, which are the names of callers:
+//
+//   - "cert:<name>" is a common name or a SAN of a client certificate, such
+//     as "cert:spiffe://example.org/billing".
+//   - "sub:<subject>" and "role:<role>" are the subject and the roles of a
+//     JWT, and "scope:<scope>" one of its scopes.
+//   - "key:<name>" is the name of an API key, and "scope:<scope>" one of its
+//     scopes.
+//   - "*" is any caller, even an anonymous one.
+//
+// Methods and principals ending with "*" match a prefix. Since REST calls
+// reach gRPC methods through the REST gateway, which forwards the callers'
+// credentials, the same rules govern native gRPC calls and REST calls.
+type Policy struct {
+	// Roles are the full method names allowed to each role, such as
+	// "/pkg.Service/Get*" or "/pkg.Service/*".
+	Roles map[string][]string `json:"roles"`
+	// Bindings are the principals of each role.
+	Bindings map[string][]string `json:"bindings"`
+	// RoleClaim is the JWT claim that lists roles. The default is
+	// DefaultRoleClaim.
+	RoleClaim string `json:"roleClaim,omitempty"`
+	// DryRun logs the calls that would be denied, but allows them.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// LoadPolicy reads a policy from a JSON file, such as:
+//
+//	{
+//		"roles": {
+//			"reader": ["/pkg.Service/Get*", "/pkg.Service/List*"],
+//			"admin": ["*"]
+//		},
+//		"bindings": {
+//			"reader": ["scope:read", "key:partner-a"],
+//			"admin": ["cert:admin.example.com", "role:admin"]
+//		}
+//	}
+func LoadPolicy(path string) (*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	if len(p.Roles) == 0 {
+		return errors.New("no roles")
+	}
+	for role, methods := range p.Roles {
+		for _, method := range methods {
+			if method != "*" && !strings.HasPrefix(method, "/") {
+				return fmt.Errorf("role %q: method %q is not a full method name", role, method)
+			}
+		}
+	}
+	for role := range p.Bindings {
+		if _, ok := p.Roles[role]; !ok {
+			return fmt.Errorf("binding of undefined role %q", role)
+		}
+	}
+	return nil
+}
+
+// principals returns the names of a gRPC caller.
+func (p *Policy) principals(ctx context.Context) []string {
+	var names []string
+	if id, ok := FromContext(ctx); ok {
+		for _, name := range id.Names() {
+			names = append(names, "cert:"+name)
+		}
+	}
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		if sub := claims.Subject(); sub != "" {
+			names = append(names, "sub:"+sub)
+		}
+		roleClaim := p.RoleClaim
+		if roleClaim == "" {
+			roleClaim = DefaultRoleClaim
+		}
+		for _, role := range claims.Strings(roleClaim) {
+			names = append(names, "role:"+role)
+		}
+		for _, claim := range []string{"scope", "scp"} {
+			for _, scope := range claims.Strings(claim) {
+				names = append(names, "scope:"+scope)
+			}
+		}
+	}
+	if key, ok := APIKeyFromContext(ctx); ok {
+		names = append(names, "key:"+key.Name)
+		for _, scope := range key.Scopes {
+			names = append(names, "scope:"+scope)
+		}
+	}
+	return names
+}
+
+// allowed reports whether one of the principals has a role that is
+// allowed a method.
+func (p *Policy) allowed(method string, principals []string) bool {
+	for role, members := range p.Bindings {
+		if !matchMethod(p.Roles[role], method) {
+			continue
+		}
+		for _, member := range members {
+			if member == "*" || matchAny(member, principals) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchAny reports whether a pattern matches one of the names.
+func matchAny(pattern string, names []string) bool {
+	for _, name := range names {
+		if matchMethod([]string{pattern}, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize authorizes a gRPC call, and audits denials.
+func (p *Policy) authorize(ctx context.Context, method string, public []string) error {
+	if matchMethod(public, method) {
+		return nil
+	}
+	principals := p.principals(ctx)
+	if p.allowed(method, principals) {
+		return nil
+	}
+	caller := strings.Join(principals, ",")
+	if caller == "" {
+		caller = "anonymous"
+	}
+	if p.DryRun {
+		middleware.Logf(ctx, "Authorization denied (dry run): method=%s principals=%s", method, caller)
+		return nil
+	}
+	middleware.Logf(ctx, "Authorization denied: method=%s principals=%s", method, caller)
+	return status.Errorf(codes.PermissionDenied, "%s is not allowed", method)
+}
+
+// UnaryServerInterceptor authorizes unary gRPC calls. It should follow the
+// authentication interceptors. Public methods are not authorized; if
+// public is nil, DefaultPublicMethods are public.
+func (p *Policy) UnaryServerInterceptor(public []string) grpc.UnaryServerInterceptor {
+	if public == nil {
+		public = DefaultPublicMethods
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := p.authorize(ctx, info.FullMethod, public); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authorizes streaming gRPC calls. It should follow
+// the authentication interceptors. Public methods are not authorized; if
+// public is nil, DefaultPublicMethods are public.
+func (p *Policy) StreamServerInterceptor(public []string) grpc.StreamServerInterceptor {
+	if public == nil {
+		public = DefaultPublicMethods
+	}
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := p.authorize(stream.Context(), info.FullMethod, public); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testPolicy = Policy{
+	Roles: map[string][]string{
+		"reader": {"/pkg.Service/Get*", "/pkg.Service/List*"},
+		"writer": {"/pkg.Service/Put"},
+		"admin":  {"*"},
+		"public": {"/pkg.Public/*"},
+	},
+	Bindings: map[string][]string{
+		"reader": {"scope:read", "key:partner-*"},
+		"writer": {"sub:alice", "cert:spiffe://example.org/billing/*"},
+		"admin":  {"role:admin", "cert:admin.example.com"},
+		"public": {"*"},
+	},
+}
+
+func TestPolicyAllowed(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		principals []string
+		want       bool
+	}{
+		{name: "method prefix", method: "/pkg.Service/GetThing", principals: []string{"scope:read"}, want: true},
+		{name: "other method prefix", method: "/pkg.Service/ListThings", principals: []string{"scope:read"}, want: true},
+		{name: "method not allowed", method: "/pkg.Service/Put", principals: []string{"scope:read"}},
+		{name: "exact method", method: "/pkg.Service/Put", principals: []string{"sub:alice"}, want: true},
+		{name: "exact method is not a prefix", method: "/pkg.Service/PutMany", principals: []string{"sub:alice"}},
+		{name: "principal prefix", method: "/pkg.Service/Get", principals: []string{"key:partner-a"}, want: true},
+		{name: "principal prefix of another kind", method: "/pkg.Service/Get", principals: []string{"sub:partner-a"}},
+		{name: "spiffe prefix", method: "/pkg.Service/Put", principals: []string{"cert:spiffe://example.org/billing/api"}, want: true},
+		{name: "spiffe prefix mismatch", method: "/pkg.Service/Put", principals: []string{"cert:spiffe://example.org/shipping/api"}},
+		{name: "any method", method: "/other.Service/Delete", principals: []string{"role:admin"}, want: true},
+		{name: "any caller", method: "/pkg.Public/Ping", want: true},
+		{name: "anonymous", method: "/pkg.Service/Get"},
+		{name: "one of many principals", method: "/pkg.Service/Delete", principals: []string{"scope:read", "cert:admin.example.com"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testPolicy.allowed(tt.method, tt.principals); got != tt.want {
+				t.Errorf("allowed(%q, %v) = %v, want %v", tt.method, tt.principals, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	background := context.Background()
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		method   string
+		dryRun   bool
+		wantCode codes.Code
+	}{
+		{name: "allowed", ctx: NewAPIKeyContext(background, &APIKey{Name: "partner-a"}), method: "/pkg.Service/Get"},
+		{name: "denied", ctx: NewAPIKeyContext(background, &APIKey{Name: "partner-a"}), method: "/pkg.Service/Put", wantCode: codes.PermissionDenied},
+		{name: "denied in dry run", ctx: NewAPIKeyContext(background, &APIKey{Name: "partner-a"}), method: "/pkg.Service/Put", dryRun: true},
+		{name: "anonymous denied", ctx: background, method: "/pkg.Service/Get", wantCode: codes.PermissionDenied},
+		{name: "anonymous denied in dry run", ctx: background, method: "/pkg.Service/Get", dryRun: true},
+		{name: "public method", ctx: background, method: "/grpc.health.v1.Health/Check"},
+		{name: "key scope", ctx: NewAPIKeyContext(background, &APIKey{Name: "other", Scopes: []string{"read"}}), method: "/pkg.Service/List"},
+		{name: "certificate", ctx: NewContext(background, &Identity{CommonName: "admin.example.com"}), method: "/pkg.Service/Delete"},
+		{name: "jwt role", ctx: NewClaimsContext(background, Claims{"roles": []interface{}{"admin"}}), method: "/pkg.Service/Delete"},
+		{name: "jwt scope", ctx: NewClaimsContext(background, Claims{"scope": "write read"}), method: "/pkg.Service/Get"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testPolicy
+			p.DryRun = tt.dryRun
+			err := p.authorize(tt.ctx, tt.method, DefaultPublicMethods)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Errorf("authorize() = %v, want %v", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "valid", content: `{"roles": {"reader": ["/pkg.Service/Get*"]}, "bindings": {"reader": ["scope:read"]}, "dryRun": true}`},
+		{name: "no roles", content: `{}`, wantErr: true},
+		{name: "method is not a full method name", content: `{"roles": {"reader": ["Get*"]}}`, wantErr: true},
+		{name: "binding of undefined role", content: `{"roles": {"reader": ["*"]}, "bindings": {"writer": ["*"]}}`, wantErr: true},
+		{name: "invalid json", content: `{"roles":`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadPolicy(writeFile(t, "policy.json", []byte(tt.content)))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadPolicy() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
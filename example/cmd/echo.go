@@ -29,7 +29,10 @@ var echoCmd = &cobra.Command{
 	Short: "",
 	Long:  ``,
 	Run: func(cmd *cobra.Command, args []string) {
-		conn := grpcgw.NewGRPCConnection()
+		conn, err := grpcgw.DialGRPC()
+		if err != nil {
+			log.Fatalf("Failed connecting to server: %s", err)
+		}
 		defer conn.Close()
 
 		client := example.NewClient(conn)
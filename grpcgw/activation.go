@@ -0,0 +1,180 @@
+package grpcgw
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	unixScheme = "unix://"
+	// fdScheme selects a listener inherited by systemd socket activation.
+	// "fd://" selects the first inherited listener, "fd://<name>" selects
+	// a listener by its FileDescriptorName and "fd://<number>" selects a
+	// listener by its file descriptor number. Servers that inherit more
+	// than one listener must select each one explicitly.
+	fdScheme = "fd://"
+	// listenFdsStart is the first file descriptor passed by systemd.
+	listenFdsStart = 3
+)
+
+var (
+	activatedOnce sync.Once
+	activated     []activatedListener
+	activatedErr  error
+)
+
+// activatedListener is a listener inherited from systemd.
+type activatedListener struct {
+	fd   int
+	name string
+	net.Listener
+}
+
+// listenAddress listens on a "host:port", "unix://<path>" or "fd://"
+// address.
+func listenAddress(address string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(address, unixScheme):
+		path := strings.TrimPrefix(address, unixScheme)
+		removeStaleSocket(path)
+		return net.Listen("unix", path)
+	case strings.HasPrefix(address, fdScheme):
+		return inheritedListener(strings.TrimPrefix(address, fdScheme))
+	default:
+		return net.Listen("tcp", address)
+	}
+}
+
+// removeStaleSocket removes a unix socket file left by a previous run.
+// A socket is stale only if connecting to it is refused. Sockets that
+// are served by a running process, and files that are not sockets, are
+// kept, so listening on them fails.
+func removeStaleSocket(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		os.Remove(path)
+	}
+}
+
+// checkInherited checks that the addresses of a server select distinct
+// inherited listeners. A bare "fd://" is ambiguous if more than one
+// address is inherited, since it would select the same listener as the
+// others.
+func checkInherited(addresses []string) error {
+	var selectors []string
+	for _, address := range addresses {
+		if strings.HasPrefix(address, fdScheme) {
+			selectors = append(selectors, strings.TrimPrefix(address, fdScheme))
+		}
+	}
+	if len(selectors) < 2 {
+		return nil
+	}
+	seen := map[string]bool{}
+	for _, selector := range selectors {
+		if selector == "" {
+			return &ListenError{Address: fdScheme, Err: errors.New("more than one address is inherited, select each listener by name or file descriptor number")}
+		}
+		if seen[selector] {
+			return &ListenError{Address: fdScheme + selector, Err: errors.New("inherited listener is selected by more than one address")}
+		}
+		seen[selector] = true
+	}
+	return nil
+}
+
+// inheritedListener returns the listener inherited from systemd that
+// matches a name or a file descriptor number. An empty selector
+// selects the first inherited listener.
+func inheritedListener(selector string) (net.Listener, error) {
+	activatedOnce.Do(func() {
+		activated, activatedErr = activatedListeners()
+	})
+	if activatedErr != nil {
+		return nil, activatedErr
+	}
+	for _, l := range activated {
+		if selector == "" || selector == l.name || selector == strconv.Itoa(l.fd) {
+			return l.Listener, nil
+		}
+	}
+	return nil, fmt.Errorf("no inherited listener matches %q", selector)
+}
+
+// activatedListeners returns the listeners passed by systemd socket
+// activation, according to the LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES
+// environment variables. The variables are unset, so they are not
+// inherited by child processes.
+func activatedListeners() ([]activatedListener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, fmt.Errorf("no listeners were passed by socket activation")
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]activatedListener, 0, count)
+	for i := 0; i < count; i++ {
+		fd := listenFdsStart + i
+		file := os.NewFile(uintptr(fd), fmt.Sprintf("LISTEN_FD_%d", fd))
+		l, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inherited file descriptor %d: %s", fd, err)
+		}
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		listeners = append(listeners, activatedListener{fd: fd, name: name, Listener: l})
+	}
+	return listeners, nil
+}
+
+// dialAddress returns the address a gRPC client should dial to reach a
+// listener that listens on address.
+func dialAddress(address string, l net.Listener) string {
+	if !strings.HasPrefix(address, fdScheme) {
+		return address
+	}
+	if l.Addr().Network() == "unix" {
+		return unixScheme + l.Addr().String()
+	}
+	return l.Addr().String()
+}
+
+// serverName returns the TLS server name used to verify the server
+// when dialing address: the host of the address, without the port.
+func serverName(address string) string {
+	if strings.HasPrefix(address, unixScheme) {
+		return "localhost"
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil && host != "" {
+		return host
+	}
+	return address
+}
@@ -0,0 +1,140 @@
+package grpcgw
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckInherited(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []string
+		wantErr   string
+	}{
+		{name: "none", addresses: []string{":8080", "unix:///run/grpcgw.sock"}},
+		{name: "single bare", addresses: []string{"fd://", ":8081"}},
+		{name: "named", addresses: []string{"fd://grpc", "fd://admin"}},
+		{name: "numbered", addresses: []string{"fd://3", "fd://4"}},
+		{name: "bare with another", addresses: []string{"fd://", "fd://admin"}, wantErr: "fd://"},
+		{name: "duplicate", addresses: []string{"fd://grpc", "fd://grpc"}, wantErr: "fd://grpc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkInherited(tt.addresses)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got error %s", err)
+				}
+				return
+			}
+			var listenErr *ListenError
+			if !errors.As(err, &listenErr) {
+				t.Fatalf("got error %v, want a ListenError", err)
+			}
+			if listenErr.Address != tt.wantErr {
+				t.Errorf("address = %q, want %q", listenErr.Address, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServerName(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{address: "localhost:8080", want: "localhost"},
+		{address: "api.example.com:443", want: "api.example.com"},
+		{address: "[::1]:8080", want: "::1"},
+		{address: ":8080", want: ":8080"},
+		{address: "unix:///run/grpcgw.sock", want: "localhost"},
+		{address: "api.example.com", want: "api.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := serverName(tt.address); got != tt.want {
+				t.Errorf("serverName(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialAddress(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	path := filepath.Join(t.TempDir(), "grpcgw.sock")
+	unix, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close()
+
+	tests := []struct {
+		name    string
+		address string
+		l       net.Listener
+		want    string
+	}{
+		{name: "tcp", address: "localhost:8080", l: tcp, want: "localhost:8080"},
+		{name: "unix", address: "unix://" + path, l: unix, want: "unix://" + path},
+		{name: "inherited tcp", address: "fd://", l: tcp, want: tcp.Addr().String()},
+		{name: "inherited unix", address: "fd://grpc", l: unix, want: "unix://" + path},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dialAddress(tt.address, tt.l); got != tt.want {
+				t.Errorf("dialAddress(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	live := filepath.Join(dir, "live.sock")
+	l, err := net.Listen("unix", live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	stale := filepath.Join(dir, "stale.sock")
+	sl, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keep the socket file after closing, as a killed process does.
+	sl.(*net.UnixListener).SetUnlinkOnClose(false)
+	sl.Close()
+
+	regular := filepath.Join(dir, "regular")
+	if err := os.WriteFile(regular, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantExists bool
+	}{
+		{name: "live", path: live, wantExists: true},
+		{name: "stale", path: stale, wantExists: false},
+		{name: "regular", path: regular, wantExists: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removeStaleSocket(tt.path)
+			_, err := os.Stat(tt.path)
+			if exists := err == nil; exists != tt.wantExists {
+				t.Errorf("exists = %v, want %v", exists, tt.wantExists)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package grpcgw
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	caCertFile     = "ca.pem"
+	caKeyFile      = "ca.key"
+	serverCertFile = "server.pem"
+	serverKeyFile  = "server.key"
+)
+
+// certsConfig configures the certificates written by the certs command.
+type certsConfig struct {
+	// Dir is the directory the certificates are written to.
+	Dir string
+	// Addresses are the addresses the server listens on. The server
+	// certificate is valid for their hosts.
+	Addresses []string
+	// Clients are the common names of client certificates to write.
+	Clients []string
+	// Validity is the validity period of the certificates.
+	Validity time.Duration
+}
+
+// writeCerts writes a local CA, a server certificate and client
+// certificates to the config's directory. An existing CA in the directory
+// is reused, so more client certificates can be added later.
+func writeCerts(c certsConfig) error {
+	for _, name := range c.Clients {
+		// Client names are part of file names, which must stay in the
+		// directory.
+		if name == "" || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("invalid client name %q: must be non-empty and without path separators", name)
+		}
+	}
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return err
+	}
+	ca, caKey, err := loadCA(c.Dir)
+	if os.IsNotExist(err) {
+		ca, caKey, err = newCA(c.Validity)
+		if err == nil {
+			err = writeKeyPair(c.Dir, caCertFile, caKeyFile, ca, caKey)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// The server certificate also authenticates the REST gateway when it
+	// dials the server in loopback mode.
+	hosts := certHosts(c.Addresses)
+	server, serverKey, err := newLeaf(ca, caKey, hosts[0], hosts, c.Validity, x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth)
+	if err != nil {
+		return err
+	}
+	if err := writeKeyPair(c.Dir, serverCertFile, serverKeyFile, server, serverKey); err != nil {
+		return err
+	}
+
+	for _, name := range c.Clients {
+		client, clientKey, err := newLeaf(ca, caKey, name, nil, c.Validity, x509.ExtKeyUsageClientAuth)
+		if err != nil {
+			return err
+		}
+		err = writeKeyPair(c.Dir, "client-"+name+".pem", "client-"+name+".key", client, clientKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// devCertificate returns an ephemeral self-signed certificate for the hosts
+// of an address, and a pool that trusts it.
+func devCertificate(address string) (tls.Certificate, *x509.CertPool, error) {
+	hosts := certHosts([]string{address})
+	ca, caKey, err := newCA(24 * time.Hour)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	cert, key, err := newLeaf(ca, caKey, hosts[0], hosts, 24*time.Hour, x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return tls.Certificate{Certificate: [][]byte{cert.Raw, ca.Raw}, PrivateKey: key, Leaf: cert}, pool, nil
+}
+
+// certHosts returns the hosts a server certificate should be valid for,
+// to serve on addresses. Wildcard and unix socket addresses are served
+// for the local host.
+func certHosts(addresses []string) []string {
+	var hosts []string
+	seen := map[string]bool{}
+	add := func(host string) {
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	for _, address := range addresses {
+		host := address
+		if h, _, err := net.SplitHostPort(address); err == nil {
+			host = h
+		}
+		switch {
+		case strings.HasPrefix(address, unixScheme), strings.HasPrefix(address, fdScheme),
+			host == "", host == "0.0.0.0", host == "::":
+			add("localhost")
+			add("127.0.0.1")
+			add("::1")
+			if name, err := os.Hostname(); err == nil {
+				add(name)
+			}
+		default:
+			add(host)
+		}
+	}
+	if len(hosts) == 0 {
+		add("localhost")
+	}
+	return hosts
+}
+
+func newCA(validity time.Duration) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := certTemplate("grpcgw local CA", validity)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+func newLeaf(ca *x509.Certificate, caKey crypto.Signer, commonName string, hosts []string, validity time.Duration, usages ...x509.ExtKeyUsage) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := certTemplate(commonName, validity)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = usages
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+func certTemplate(commonName string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+	}, nil
+}
+
+// loadCA loads the CA of a directory. If the directory has no CA, the
+// error satisfies os.IsNotExist. If it has only the CA certificate or only
+// its key, loadCA fails, so a new CA never overwrites half of an existing
+// one.
+func loadCA(dir string) (*x509.Certificate, crypto.Signer, error) {
+	certPath, keyPath := filepath.Join(dir, caCertFile), filepath.Join(dir, caKeyFile)
+	_, certErr := os.Stat(certPath)
+	_, keyErr := os.Stat(keyPath)
+	switch {
+	case os.IsNotExist(certErr) && os.IsNotExist(keyErr):
+		return nil, nil, certErr
+	case os.IsNotExist(certErr):
+		return nil, nil, fmt.Errorf("CA key %s exists without its certificate %s", keyPath, certPath)
+	case os.IsNotExist(keyErr):
+		return nil, nil, fmt.Errorf("CA certificate %s exists without its key %s", certPath, keyPath)
+	}
+	keyPair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := keyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("CA key in %s can't sign", dir)
+	}
+	return cert, key, nil
+}
+
+func writeKeyPair(dir, certFile, keyFile string, cert *x509.Certificate, key crypto.Signer) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := ioutil.WriteFile(filepath.Join(dir, certFile), certPEM, 0644); err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return ioutil.WriteFile(filepath.Join(dir, keyFile), keyPEM, 0600)
+}
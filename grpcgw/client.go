@@ -1,40 +1,64 @@
 package grpcgw
 
 import (
+	"crypto/tls"
 	"crypto/x509"
+	"io/ioutil"
+	"log"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/grpclog"
-	"io/ioutil"
-	"log"
 )
 
 type client struct {
-	Address string
+	Address  string
 	CertFile string
-	Insecure bool
+	// ClientCertFile and ClientKeyFile are the client's certificate and
+	// key, presented to servers that require client certificates.
+	ClientCertFile string
+	ClientKeyFile  string
+	Insecure       bool
 }
 
-func NewGRPCConnection() *grpc.ClientConn {
+// DialGRPC returns a connection to the server of the client flags. It
+// returns a *TLSError if the certificates could not be loaded.
+func DialGRPC() (*grpc.ClientConn, error) {
 	var opts []grpc.DialOption
 	if Client.CertFile != "" {
 		cert, err := ioutil.ReadFile(Client.CertFile)
 		if err != nil {
-			log.Fatalf("Failed reading pem file %s", Client.CertFile)
+			return nil, &TLSError{File: Client.CertFile, Err: err}
 		}
 		certPool := x509.NewCertPool()
-		certPool.AppendCertsFromPEM(cert)
-		creds := credentials.NewClientTLSFromCert(certPool, Client.Address)
+		if !certPool.AppendCertsFromPEM(cert) {
+			return nil, &TLSError{File: Client.CertFile, Err: ErrInvalidCert}
+		}
+		tlsConfig := &tls.Config{ServerName: serverName(Client.Address), RootCAs: certPool}
+		if Client.ClientCertFile != "" {
+			keyPair, err := tls.LoadX509KeyPair(Client.ClientCertFile, Client.ClientKeyFile)
+			if err != nil {
+				return nil, &TLSError{File: Client.ClientCertFile, Err: err}
+			}
+			tlsConfig.Certificates = []tls.Certificate{keyPair}
+		}
+		creds := credentials.NewTLS(tlsConfig)
 		opts = append(opts, grpc.WithTransportCredentials(creds))
 	} else {
 		if Client.Insecure {
 			opts = append(opts, grpc.WithInsecure())
 		}
 	}
-	conn, err := grpc.Dial(Client.Address, opts...)
+	return grpc.Dial(Client.Address, opts...)
+}
+
+// NewGRPCConnection returns a connection to the server of the client flags,
+// and exits the process if it fails.
+//
+// Deprecated: use DialGRPC, which returns an error instead.
+func NewGRPCConnection() *grpc.ClientConn {
+	conn, err := DialGRPC()
 	if err != nil {
-		grpclog.Fatalf("fail to dial: %v", err)
+		log.Fatalf("Failed connecting to %s: %s", Client.Address, err)
 	}
 	return conn
 }
@@ -0,0 +1,46 @@
+package grpcgw
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDialGRPC(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(invalid, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.pem")
+
+	tests := []struct {
+		name     string
+		client   client
+		wantFile string
+		wantErr  error
+	}{
+		{name: "insecure", client: client{Address: "localhost:10000", Insecure: true}},
+		{name: "missing ca", client: client{Address: "localhost:10000", CertFile: missing}, wantFile: missing, wantErr: os.ErrNotExist},
+		{name: "invalid ca", client: client{Address: "localhost:10000", CertFile: invalid}, wantFile: invalid, wantErr: ErrInvalidCert},
+	}
+	defer func(c client) { Client = c }(Client)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Client = tt.client
+			conn, err := DialGRPC()
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("got error %s", err)
+				}
+				conn.Close()
+				return
+			}
+			var tlsErr *TLSError
+			if !errors.As(err, &tlsErr) || tlsErr.File != tt.wantFile || !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want a TLSError of %s: %v", err, tt.wantFile, tt.wantErr)
+			}
+		})
+	}
+}
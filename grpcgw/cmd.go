@@ -2,8 +2,18 @@ package grpcgw
 
 import (
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/posener/grpcgw/auth"
+	"github.com/posener/grpcgw/metrics"
 	"github.com/posener/grpcgw/middleware"
+	"github.com/posener/grpcgw/ratelimit"
+	"github.com/posener/grpcgw/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
 	"github.com/spf13/cobra"
 	"context"
@@ -11,32 +21,110 @@ import (
 
 var (
 	noAPICallsLogging bool
-	Client client
+	noMetrics         bool
+	noRecovery        bool
+	traceConfig       tracing.Config
+	headerPolicyFile  string
+	rateLimitFile     string
+	jwtConfig         auth.JWTConfig
+	apiKeysConfig     auth.APIKeysConfig
+	policyFile        string
+	policyDryRun      bool
+	Client            client
 )
 
 const (
-	defaultAddress = "localhost:10000"
+	defaultAddress         = "localhost:10000"
+	defaultShutdownTimeout = 30 * time.Second
+
+	// Defaults of the serve command limits. The body and message sizes
+	// match the gRPC default of received messages.
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultMaxMessageSize    = 4 << 20
 )
 
-func AddCommands(rootCmd *cobra.Command, service Service) {
-	s := NewServer(service)
+// AddCommands adds the serve and send commands to the root command.
+// The serve command serves all the given services on the same server.
+func AddCommands(rootCmd *cobra.Command, service Service, more ...Service) {
+	s := NewServer(service, WithServices(more...))
 	serveCmd := newServeCommand(s)
-	serveCmd.Flags().StringVarP(&s.Address, "address", "a", defaultAddress, "Listen address")
+	serveCmd.Flags().StringVarP(&s.Address, "address", "a", defaultAddress, "Listen address: host:port, unix://<path> or fd://[<name>|<number>] for systemd socket activation")
 	serveCmd.Flags().BoolVar(&noAPICallsLogging, "no-api-log", false, "Don't log API calls")
+	serveCmd.Flags().BoolVar(&noMetrics, "no-metrics", false, "Don't record and serve metrics")
+	serveCmd.Flags().BoolVar(&noRecovery, "no-recovery", false, "Don't recover panics of REST handlers and gRPC methods")
+	serveCmd.Flags().StringVar(&traceConfig.Exporter, "trace-exporter", tracing.ExporterNone, "Trace span exporter: none, stdout or otlp")
+	serveCmd.Flags().StringVar(&traceConfig.Endpoint, "trace-endpoint", "", "Address of the OTLP trace collector (default is $OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4317)")
+	serveCmd.Flags().BoolVar(&traceConfig.Insecure, "trace-insecure", false, "Connect to the OTLP trace collector without TLS")
+	serveCmd.Flags().Float64Var(&traceConfig.SampleRatio, "trace-sample-ratio", 1, "Ratio of new traces that are sampled")
+	serveCmd.Flags().StringVar(&traceConfig.ServiceName, "trace-service-name", "", "Service name in the exported spans (default is the command name)")
 	serveCmd.Flags().StringVar(&s.KeyFile, "key", "", "Private key file")
 	serveCmd.Flags().StringVar(&s.CertFile, "crt", "", "CA Certificate file")
+	serveCmd.Flags().StringVar(&s.ClientCAFile, "client-ca", "", "CA file that verifies client certificates; required by --client-auth request and verify")
+	serveCmd.Flags().StringVar(&s.ClientAuth, "client-auth", "none", "Client certificate authentication: none, request, require or verify")
+	serveCmd.Flags().BoolVar(&s.Plaintext, "plaintext", false, "Serve without TLS, over HTTP/1.1 and h2c")
+	addEndpointFlags(serveCmd, &s.GRPC, "grpc", "gRPC")
+	addEndpointFlags(serveCmd, &s.HTTP, "http", "REST")
+	addEndpointFlags(serveCmd, &s.Admin, "admin", "admin endpoints")
+	serveCmd.Flags().BoolVar(&s.GatewayLoopback, "gateway-loopback", false, "REST gateway dials the listen address instead of an in-process connection")
+	serveCmd.Flags().StringVar(&headerPolicyFile, "header-policy", "", "JSON file of headers forwarded by the REST gateway: {\"incoming\": [...], \"outgoing\": [...]}")
+	serveCmd.Flags().StringSliceVar(&s.Headers.Incoming, "forward-header", nil, "Request headers forwarded to gRPC methods as metadata; a trailing * matches a prefix")
+	serveCmd.Flags().StringSliceVar(&s.Headers.Outgoing, "forward-response-header", nil, "Response metadata and trailers forwarded to REST callers as headers; a trailing * matches a prefix")
+	serveCmd.Flags().BoolVar(&s.ProblemErrors, "problem-errors", false, "Reply to REST errors with RFC 7807 application/problem+json bodies")
+	serveCmd.Flags().StringSliceVar(&s.CORS.AllowedOrigins, "cors-origin", nil, "Origins allowed to make cross-origin REST calls; * allows any, https://*.example.com allows subdomains")
+	serveCmd.Flags().StringSliceVar(&s.CORS.AllowedMethods, "cors-method", middleware.DefaultCORSMethods, "Methods allowed in cross-origin REST calls, further limited to the methods of the gateway routes of each path")
+	serveCmd.Flags().StringSliceVar(&s.CORS.AllowedHeaders, "cors-header", nil, "Request headers allowed in cross-origin REST calls; * allows any")
+	serveCmd.Flags().BoolVar(&s.CORS.AllowCredentials, "cors-credentials", false, "Allow cross-origin REST calls with credentials")
+	serveCmd.Flags().DurationVar(&s.CORS.MaxAge, "cors-max-age", 0, "Time browsers may cache preflight responses")
+	serveCmd.Flags().StringVar(&jwtConfig.KeyFile, "jwt-keys", "", "JWKS or PEM file of keys that verify JWT bearer tokens; enables JWT authentication")
+	serveCmd.Flags().StringVar(&jwtConfig.Issuer, "jwt-issuer", "", "Required issuer (iss) of JWT bearer tokens")
+	serveCmd.Flags().StringVar(&jwtConfig.Audience, "jwt-audience", "", "Required audience (aud) of JWT bearer tokens")
+	serveCmd.Flags().DurationVar(&jwtConfig.ClockSkew, "jwt-clock-skew", time.Minute, "Allowed clock skew when validating JWT bearer tokens")
+	serveCmd.Flags().StringVar(&apiKeysConfig.KeyFile, "api-keys", "", "JSON file of SHA-256 hashed API keys, reloaded when it changes; enables API key authentication")
+	serveCmd.Flags().StringVar(&apiKeysConfig.Header, "api-key-header", auth.DefaultAPIKeyHeader, "Header, and lower case metadata key, of API keys")
+	serveCmd.Flags().StringVar(&apiKeysConfig.Param, "api-key-param", auth.DefaultAPIKeyParam, "Query parameter of API keys of REST calls")
+	serveCmd.Flags().BoolVar(&s.CertificateAuthentication, "cert-authn", false, "Authenticate callers with a client certificate verified by --client-auth, next to JWT and API keys")
+	serveCmd.Flags().StringSliceVar(&s.PublicMethods, "public-method", auth.DefaultPublicMethods, "gRPC methods open to unauthenticated callers; a trailing * matches a prefix")
+	serveCmd.Flags().StringVar(&policyFile, "policy", "", "JSON file of the roles and the allowed gRPC methods of callers; enables authorization")
+	serveCmd.Flags().BoolVar(&policyDryRun, "policy-dry-run", false, "Log the calls that the policy would deny, but allow them")
+	serveCmd.Flags().StringVar(&rateLimitFile, "rate-limit", "", "JSON file of rate limits of REST paths and gRPC methods")
+	serveCmd.Flags().DurationVar(&s.Limits.ReadHeaderTimeout, "read-header-timeout", defaultReadHeaderTimeout, "Maximal time to read request headers")
+	serveCmd.Flags().DurationVar(&s.Limits.ReadTimeout, "read-timeout", 0, "Maximal time to read requests, including bodies and gRPC streams; 0 is unlimited")
+	serveCmd.Flags().DurationVar(&s.Limits.WriteTimeout, "write-timeout", 0, "Maximal time to write responses, including gRPC streams; 0 is unlimited")
+	serveCmd.Flags().DurationVar(&s.Limits.IdleTimeout, "idle-timeout", defaultIdleTimeout, "Maximal time to keep idle connections open")
+	serveCmd.Flags().IntVar(&s.Limits.MaxHeaderBytes, "max-header-bytes", http.DefaultMaxHeaderBytes, "Maximal size of request headers")
+	serveCmd.Flags().Int64Var(&s.Limits.Body.MaxBytes, "max-body-bytes", defaultMaxMessageSize, "Maximal size of REST request bodies; 0 is unlimited")
+	serveCmd.Flags().StringToInt64Var(&s.Limits.Body.Routes, "route-max-body-bytes", nil, "Maximal size of REST request bodies by path, such as /v1/upload/*=16777216; a trailing * matches a prefix")
+	serveCmd.Flags().IntVar(&s.Limits.MaxRecvMsgSize, "grpc-max-recv-msg-size", defaultMaxMessageSize, "Maximal size of received gRPC messages, including those of REST calls")
+	serveCmd.Flags().IntVar(&s.Limits.MaxSendMsgSize, "grpc-max-send-msg-size", 0, "Maximal size of sent gRPC messages, including those of REST calls; 0 keeps the gRPC default")
 	serveCmd.Flags().StringVar(&s.SwaggersPath, "swaggers", "", "A directory containing swagger files")
+	serveCmd.Flags().DurationVar(&s.ShutdownTimeout, "shutdown-timeout", defaultShutdownTimeout, "Maximal time to wait for in-flight requests on shutdown")
+	serveCmd.Flags().BoolVar(&s.Reflection, "reflection", false, "Register the gRPC server reflection service")
+	serveCmd.Flags().StringVar(&s.ReflectionAccess, "reflection-access", ReflectionAll, "Callers allowed to use reflection: all, authenticated or admin")
+	serveCmd.Flags().StringSliceVar(&s.AdminIdentities, "admin-identity", nil, "Identities of admin callers: certificate common names, SANs or SPIFFE IDs")
+	serveCmd.Flags().BoolVar(&s.Dev, "dev", false, "Serve with an ephemeral self-signed certificate when no certificate is given")
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(newCertsCommand())
 
 	Client = client{}
 	SendCmd.PersistentFlags().StringVarP(&Client.Address, "url", "u", defaultAddress, "Listen address")
 	SendCmd.PersistentFlags().StringVar(&Client.CertFile, "crt", "", "CA Certificate file")
+	SendCmd.PersistentFlags().StringVar(&Client.ClientCertFile, "client-crt", "", "Client certificate file, for mutual TLS")
+	SendCmd.PersistentFlags().StringVar(&Client.ClientKeyFile, "client-key", "", "Client private key file, for mutual TLS")
 	SendCmd.PersistentFlags().BoolVar(&Client.Insecure, "insecure", false, "Use insecure connection")
 	rootCmd.AddCommand(SendCmd)
 }
 
+// addEndpointFlags adds flags configuring a separate listener.
+func addEndpointFlags(cmd *cobra.Command, e *Endpoint, name, traffic string) {
+	cmd.Flags().StringVar(&e.Address, name+"-address", "", "Separate listen address for "+traffic)
+	cmd.Flags().StringVar(&e.KeyFile, name+"-key", "", "Private key file of the "+name+" listener (default is --key)")
+	cmd.Flags().StringVar(&e.CertFile, name+"-crt", "", "Certificate file of the "+name+" listener (default is --crt)")
+	cmd.Flags().BoolVar(&e.Plaintext, name+"-plaintext", false, "Serve the "+name+" listener without TLS")
+}
+
 // serveCmd represents the serve command
-func newServeCommand(s *server) *cobra.Command {
+func newServeCommand(s *Server) *cobra.Command {
 	return &cobra.Command{
 		Use:   "serve",
 		Short: "Run grpcgw example server",
@@ -45,11 +133,128 @@ func newServeCommand(s *server) *cobra.Command {
 			if !noAPICallsLogging {
 				s.Middleware = s.Middleware.Append(middleware.APILoggerMiddleware)
 			}
-			Serve(s, context.Background())
+			if !noRecovery {
+				WithRecovery()(s)
+			}
+			if !noMetrics {
+				s.Metrics = metrics.New()
+			}
+			if headerPolicyFile != "" {
+				p, err := LoadHeaderPolicy(headerPolicyFile)
+				if err != nil {
+					log.Fatal(err)
+				}
+				s.Headers.Incoming = append(s.Headers.Incoming, p.Incoming...)
+				s.Headers.Outgoing = append(s.Headers.Outgoing, p.Outgoing...)
+			}
+			if jwtConfig.KeyFile != "" {
+				j, err := auth.NewJWT(jwtConfig)
+				if err != nil {
+					log.Fatalf("Failed loading JWT keys: %s", err)
+				}
+				s.JWT = j
+			}
+			if apiKeysConfig.KeyFile != "" {
+				a, err := auth.NewAPIKeys(apiKeysConfig)
+				if err != nil {
+					log.Fatalf("Failed loading API keys: %s", err)
+				}
+				s.APIKeys = a
+			}
+			if policyFile != "" {
+				p, err := auth.LoadPolicy(policyFile)
+				if err != nil {
+					log.Fatalf("Failed loading policy: %s", err)
+				}
+				p.DryRun = p.DryRun || policyDryRun
+				s.Policy = p
+			}
+			if rateLimitFile != "" {
+				c, err := ratelimit.LoadConfig(rateLimitFile)
+				if err != nil {
+					log.Fatalf("Failed loading rate limits: %s", err)
+				}
+				if s.RateLimit, err = ratelimit.New(c); err != nil {
+					log.Fatalf("Failed loading rate limits: %s", err)
+				}
+			}
+			provider := newTracerProvider(cmd)
+			if provider != nil {
+				s.Tracing = tracing.New(provider)
+			}
+			err := s.Run(signalContext())
+			if provider != nil {
+				if err := provider.Shutdown(context.Background()); err != nil {
+					log.Printf("Failed exporting the remaining spans: %s", err)
+				}
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
 		},
 	}
 }
 
+// newTracerProvider returns the tracer provider configured by the trace
+// flags, or nil if tracing is disabled.
+func newTracerProvider(cmd *cobra.Command) *sdktrace.TracerProvider {
+	if traceConfig.ServiceName == "" {
+		traceConfig.ServiceName = cmd.Root().Name()
+	}
+	exporter, err := tracing.NewExporter(context.Background(), traceConfig)
+	if err != nil {
+		log.Fatalf("Failed creating trace exporter: %s", err)
+	}
+	if exporter == nil {
+		return nil
+	}
+	return tracing.NewProvider(exporter, traceConfig)
+}
+
+// newCertsCommand returns the certs command, which writes a local CA,
+// a server certificate and client certificates.
+func newCertsCommand() *cobra.Command {
+	var (
+		c    certsConfig
+		days int
+	)
+	cmd := &cobra.Command{
+		Use:   "certs",
+		Short: "Write a local CA, a server certificate and client certificates",
+		Long: `Write a local CA, a server certificate and client certificates.
+
+The server certificate is valid for the hosts of the given addresses.
+Client certificates, for mutual TLS, are written for every --client name.
+An existing CA in the directory is reused.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			c.Validity = time.Duration(days) * 24 * time.Hour
+			if err := writeCerts(c); err != nil {
+				log.Fatalf("Failed writing certificates: %s", err)
+			}
+			log.Printf("Certificates written to %s", c.Dir)
+		},
+	}
+	cmd.Flags().StringVarP(&c.Dir, "dir", "d", "certs", "Directory to write the certificates to")
+	cmd.Flags().StringSliceVarP(&c.Addresses, "address", "a", []string{defaultAddress}, "Addresses the server listens on")
+	cmd.Flags().StringSliceVar(&c.Clients, "client", nil, "Common names of client certificates to write")
+	cmd.Flags().IntVar(&days, "days", 365, "Validity of the certificates in days")
+	return cmd
+}
+
+// signalContext returns a context that is cancelled when the process
+// receives an interrupt or a termination signal.
+func signalContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("Got signal %s", sig)
+		cancel()
+	}()
+	return ctx
+}
+
 // sendCmd represents the send command
 var SendCmd = &cobra.Command{
 	Use:   "send",
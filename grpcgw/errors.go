@@ -0,0 +1,79 @@
+package grpcgw
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoCredentials is returned when the server was not given a key
+	// and a certificate.
+	ErrNoCredentials = errors.New("must provide a key and certificate to run server")
+	// ErrInvalidCert is returned when a certificate file contains no
+	// valid PEM certificates.
+	ErrInvalidCert = errors.New("no valid certificate found")
+	// ErrInvalidClientAuth is returned for an unknown client authentication
+	// mode.
+	ErrInvalidClientAuth = errors.New("invalid client authentication mode")
+	// ErrNoClientCA is returned when client certificates are verified but
+	// no client CA file was given.
+	ErrNoClientCA = errors.New("verifying client certificates requires a client CA file")
+	// ErrInvalidReflectionAccess is returned for an unknown reflection
+	// access mode.
+	ErrInvalidReflectionAccess = errors.New("invalid reflection access mode")
+)
+
+// TLSError is returned when the server's TLS material could not be loaded.
+type TLSError struct {
+	// File is the file that failed loading, if any.
+	File string
+	Err  error
+}
+
+func (e *TLSError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("tls: %s", e.Err)
+	}
+	return fmt.Sprintf("tls: loading %s: %s", e.File, e.Err)
+}
+
+func (e *TLSError) Unwrap() error { return e.Err }
+
+// ListenError is returned when the server failed listening or serving
+// on its address.
+type ListenError struct {
+	Address string
+	Err     error
+}
+
+func (e *ListenError) Error() string {
+	return fmt.Sprintf("listen on %s: %s", e.Address, e.Err)
+}
+
+func (e *ListenError) Unwrap() error { return e.Err }
+
+// RegisterError is returned when registering a service to the REST
+// gateway failed.
+type RegisterError struct {
+	// Service is the service that failed registering.
+	Service Service
+	Err     error
+}
+
+func (e *RegisterError) Error() string {
+	return fmt.Sprintf("registering gateway endpoints of %T: %s", e.Service, e.Err)
+}
+
+func (e *RegisterError) Unwrap() error { return e.Err }
+
+// ConfigError is returned when a configuration file could not be loaded.
+type ConfigError struct {
+	File string
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("loading config %s: %s", e.File, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error { return e.Err }
@@ -0,0 +1,125 @@
+package grpcgw
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/posener/grpcgw/auth"
+	"github.com/posener/grpcgw/middleware"
+	"golang.org/x/net/context"
+)
+
+// HeaderPolicy selects the headers that the REST gateway forwards between
+// REST callers and gRPC methods, under their own names. Other headers are
+// forwarded by grpc-gateway's default rules: "Grpc-Metadata-" prefixed
+// request headers become metadata, and response metadata becomes
+// "Grpc-Metadata-" prefixed headers.
+//
+// A name ending with "*" matches every header with that prefix. Names are
+// case-insensitive. Metadata keys starting with "grpcgw-" are reserved and
+// are never forwarded from request headers.
+type HeaderPolicy struct {
+	// Incoming are the request headers that are forwarded to gRPC methods
+	// as metadata.
+	Incoming []string `json:"incoming"`
+	// Outgoing are the response metadata and trailer keys that are
+	// forwarded to REST callers as response headers.
+	Outgoing []string `json:"outgoing"`
+}
+
+// LoadHeaderPolicy reads a header policy from a JSON file of the form
+// {"incoming": ["X-Tenant", "X-Custom-*"], "outgoing": ["X-Request-Id"]}.
+func LoadHeaderPolicy(path string) (HeaderPolicy, error) {
+	var p HeaderPolicy
+	b, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(b, &p)
+	}
+	if err != nil {
+		return p, &ConfigError{File: path, Err: err}
+	}
+	return p, nil
+}
+
+// gatewayOptions returns the gateway mux options that apply the policy.
+func (p HeaderPolicy) gatewayOptions() []runtime.ServeMuxOption {
+	return []runtime.ServeMuxOption{
+		runtime.WithIncomingHeaderMatcher(p.matchIncoming),
+		runtime.WithOutgoingHeaderMatcher(p.matchOutgoing),
+		runtime.WithForwardResponseOption(p.forwardTrailers),
+	}
+}
+
+// matchIncoming maps a request header to a metadata key.
+func (p HeaderPolicy) matchIncoming(key string) (string, bool) {
+	name, ok := key, matchHeader(p.Incoming, key)
+	if !ok {
+		name, ok = runtime.DefaultHeaderMatcher(key)
+	}
+	// The request ID is forwarded by the gateway itself, after it is
+	// validated.
+	if !ok || auth.Reserved(name) || strings.EqualFold(name, middleware.RequestIDMetadataKey) {
+		return "", false
+	}
+	return strings.ToLower(name), true
+}
+
+// standardOutgoing are response metadata keys that are always forwarded
+// under their own names, since they are meaningful to REST callers.
+var standardOutgoing = []string{"Retry-After", "WWW-Authenticate"}
+
+// matchOutgoing maps a response metadata key to a header. The request ID
+// is not forwarded, since REST responses already carry it.
+func (p HeaderPolicy) matchOutgoing(key string) (string, bool) {
+	if strings.EqualFold(key, middleware.RequestIDMetadataKey) {
+		return "", false
+	}
+	if matchHeader(p.Outgoing, key) || matchHeader(standardOutgoing, key) {
+		return key, true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+// forwardTrailers sets the allowed trailers of a successful call as
+// response headers, since REST callers rarely read HTTP trailers.
+func (p HeaderPolicy) forwardTrailers(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+	md, ok := runtime.ServerMetadataFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	for key, values := range md.TrailerMD {
+		if !p.matchTrailer(key) {
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	return nil
+}
+
+// matchTrailer reports whether a response trailer is forwarded as a
+// header.
+func (p HeaderPolicy) matchTrailer(key string) bool {
+	return matchHeader(p.Outgoing, key) && !strings.EqualFold(key, middleware.RequestIDMetadataKey)
+}
+
+// matchHeader reports whether a header matches one of the patterns.
+func matchHeader(patterns []string, key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package grpcgw
+
+import "testing"
+
+func TestHeaderPolicyMatchIncoming(t *testing.T) {
+	p := HeaderPolicy{Incoming: []string{"X-Tenant", "X-Custom-*", "Grpcgw-*", "X-Request-Id"}}
+	tests := []struct {
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{key: "X-Tenant", want: "x-tenant", wantOK: true},
+		{key: "x-tenant", want: "x-tenant", wantOK: true},
+		{key: "X-Custom-Flavor", want: "x-custom-flavor", wantOK: true},
+		{key: "X-Other", wantOK: false},
+		{key: "Grpc-Metadata-Foo", want: "foo", wantOK: true},
+		{key: "Authorization", want: "grpcgateway-authorization", wantOK: true},
+		// Reserved keys are never forwarded, even if they are allowed.
+		{key: "Grpcgw-Identity", wantOK: false},
+		{key: "Grpc-Metadata-Grpcgw-Identity", wantOK: false},
+		// The request ID is forwarded by the gateway after validation.
+		{key: "X-Request-Id", wantOK: false},
+		{key: "Grpc-Metadata-X-Request-Id", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := p.matchIncoming(tt.key)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("matchIncoming(%q) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHeaderPolicyMatchOutgoing(t *testing.T) {
+	p := HeaderPolicy{Outgoing: []string{"X-Rate-*", "x-request-id"}}
+	tests := []struct {
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{key: "x-rate-remaining", want: "x-rate-remaining", wantOK: true},
+		{key: "retry-after", want: "retry-after", wantOK: true},
+		{key: "www-authenticate", want: "www-authenticate", wantOK: true},
+		{key: "x-other", want: "Grpc-Metadata-x-other", wantOK: true},
+		// REST responses already carry the request ID.
+		{key: "x-request-id", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := p.matchOutgoing(tt.key)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("matchOutgoing(%q) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package grpcgw
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// healthCheckInterval is the interval in which services that implement
+	// HealthChecker are checked.
+	healthCheckInterval = 5 * time.Second
+	// healthCheckTimeout is the maximal duration of a single health check.
+	healthCheckTimeout = 2 * time.Second
+)
+
+// HealthChecker is optionally implemented by a Service to report its
+// health. The status of all the gRPC services registered by the Service
+// follows the result of CheckHealth, and the server is ready only if all
+// the services are healthy.
+type HealthChecker interface {
+	// CheckHealth returns nil if the service can serve requests.
+	CheckHealth(ctx context.Context) error
+}
+
+// healthReporter reports the health of the server through the standard
+// gRPC health service and through the /healthz and /readyz REST endpoints.
+type healthReporter struct {
+	server *health.Server
+	checks []healthCheck
+}
+
+// healthCheck is the health check of a service, and the names of the gRPC
+// services it registered.
+type healthCheck struct {
+	HealthChecker
+	names []string
+}
+
+func newHealthReporter() *healthReporter {
+	return &healthReporter{server: health.NewServer()}
+}
+
+// track reports the gRPC services that a service registered as serving.
+// If the service implements HealthChecker, their status follows its
+// checks.
+func (h *healthReporter) track(service Service, names []string) {
+	for _, name := range names {
+		h.server.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	if checker, ok := service.(HealthChecker); ok {
+		h.checks = append(h.checks, healthCheck{HealthChecker: checker, names: names})
+	}
+}
+
+// watch checks the services periodically until ctx is done.
+func (h *healthReporter) watch(ctx context.Context) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		h.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check updates the status of every service, and the overall status of
+// the server.
+func (h *healthReporter) check(ctx context.Context) {
+	overall := healthpb.HealthCheckResponse_SERVING
+	for _, check := range h.checks {
+		status := healthpb.HealthCheckResponse_SERVING
+		checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		if err := check.CheckHealth(checkCtx); err != nil {
+			log.Printf("Health check of %v failed: %s", check.names, err)
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+			overall = status
+		}
+		cancel()
+		for _, name := range check.names {
+			h.server.SetServingStatus(name, status)
+		}
+	}
+	h.server.SetServingStatus("", overall)
+}
+
+// shutdown marks all services as not serving, and ignores further
+// updates.
+func (h *healthReporter) shutdown() {
+	h.server.Shutdown()
+}
+
+// handle registers the health endpoints on a mux.
+func (h *healthReporter) handle(mux *http.ServeMux) {
+	handle(mux, "/healthz", handleLiveness())
+	handle(mux, "/readyz", h.handleReadiness())
+}
+
+// handleLiveness is the /healthz handler. It reports that the process is
+// serving requests.
+func handleLiveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// handleReadiness is the /readyz handler. It reports whether the server
+// and all of its services are ready to serve requests.
+func (h *healthReporter) handleReadiness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := h.server.Check(r.Context(), &healthpb.HealthCheckRequest{})
+		if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready\n"))
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+}
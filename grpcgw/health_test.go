@@ -0,0 +1,62 @@
+package grpcgw
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type testService struct{}
+
+func (testService) RegisterGRPC(*grpc.Server) {}
+
+func (testService) RegisterGatewayEndpoints(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error {
+	return nil
+}
+
+type testCheckedService struct {
+	testService
+	err error
+}
+
+func (s testCheckedService) CheckHealth(context.Context) error { return s.err }
+
+func TestHealthReporter(t *testing.T) {
+	h := newHealthReporter()
+	h.track(testService{}, []string{"pkg.Plain"})
+	h.track(testCheckedService{err: errors.New("database is down")}, []string{"pkg.Checked"})
+	h.check(context.Background())
+
+	tests := []struct {
+		service string
+		want    healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{service: "pkg.Plain", want: healthpb.HealthCheckResponse_SERVING},
+		{service: "pkg.Checked", want: healthpb.HealthCheckResponse_NOT_SERVING},
+		{service: "", want: healthpb.HealthCheckResponse_NOT_SERVING},
+	}
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			resp, err := h.server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: tt.service})
+			if err != nil {
+				t.Fatalf("check failed: %s", err)
+			}
+			if resp.Status != tt.want {
+				t.Errorf("status = %v, want %v", resp.Status, tt.want)
+			}
+		})
+	}
+
+	h.shutdown()
+	resp, err := h.server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "pkg.Plain"})
+	if err != nil {
+		t.Fatalf("check failed: %s", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status after shutdown = %v, want NOT_SERVING", resp.Status)
+	}
+}
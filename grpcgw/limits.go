@@ -0,0 +1,70 @@
+package grpcgw
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/posener/grpcgw/middleware"
+	"google.golang.org/grpc"
+)
+
+// Limits bound the time and the size of REST and gRPC calls. Zero values
+// are not limited, or use the net/http and gRPC defaults.
+type Limits struct {
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout, IdleTimeout and
+	// MaxHeaderBytes configure the http servers of all the listeners.
+	// ReadTimeout and WriteTimeout also bound the streaming gRPC calls of
+	// listeners that serve gRPC.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// Body limits the size of REST request bodies. The REST gateway also
+	// sends bodies to the gRPC server as messages, which are bound by
+	// MaxRecvMsgSize.
+	Body middleware.BodyLimitOptions
+	// MaxRecvMsgSize and MaxSendMsgSize are the maximal sizes of the
+	// messages that the gRPC server receives and sends. Zero keeps the
+	// gRPC default. The REST gateway is configured to match those that
+	// are set.
+	MaxRecvMsgSize int
+	MaxSendMsgSize int
+}
+
+// apply configures an http server.
+func (l Limits) apply(srv *http.Server) {
+	srv.ReadHeaderTimeout = l.ReadHeaderTimeout
+	srv.ReadTimeout = l.ReadTimeout
+	srv.WriteTimeout = l.WriteTimeout
+	srv.IdleTimeout = l.IdleTimeout
+	srv.MaxHeaderBytes = l.MaxHeaderBytes
+}
+
+// serverOptions returns the gRPC server options of the limits.
+func (l Limits) serverOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if l.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(l.MaxRecvMsgSize))
+	}
+	if l.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(l.MaxSendMsgSize))
+	}
+	return opts
+}
+
+// dialOptions returns the dial options of the REST gateway, so it sends
+// and receives the messages that the gRPC server receives and sends.
+func (l Limits) dialOptions() []grpc.DialOption {
+	var opts []grpc.CallOption
+	if l.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxCallSendMsgSize(l.MaxRecvMsgSize))
+	}
+	if l.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxCallRecvMsgSize(l.MaxSendMsgSize))
+	}
+	if len(opts) == 0 {
+		return nil
+	}
+	return []grpc.DialOption{grpc.WithDefaultCallOptions(opts...)}
+}
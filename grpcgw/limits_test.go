@@ -0,0 +1,27 @@
+package grpcgw
+
+import "testing"
+
+func TestLimitsOptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		limits     Limits
+		wantServer int
+		wantDial   int
+	}{
+		{name: "default"},
+		{name: "recv", limits: Limits{MaxRecvMsgSize: 4 << 20}, wantServer: 1, wantDial: 1},
+		{name: "send", limits: Limits{MaxSendMsgSize: 8 << 20}, wantServer: 1, wantDial: 1},
+		{name: "both", limits: Limits{MaxRecvMsgSize: 4 << 20, MaxSendMsgSize: 8 << 20}, wantServer: 2, wantDial: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(tt.limits.serverOptions()); got != tt.wantServer {
+				t.Errorf("got %d server options, want %d", got, tt.wantServer)
+			}
+			if got := len(tt.limits.dialOptions()); got != tt.wantDial {
+				t.Errorf("got %d dial options, want %d", got, tt.wantDial)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package grpcgw
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+)
+
+// Endpoint configures an additional listener of the server.
+type Endpoint struct {
+	// Address is a "host:port", a "unix://<path>" or a systemd socket
+	// activation "fd://[<name>|<number>]" address.
+	Address string
+	// CertFile and KeyFile are the TLS material of the listener.
+	// If none of them is set, and Plaintext is not set, the listener
+	// uses the server's TLS settings.
+	CertFile string
+	KeyFile  string
+	// Plaintext serves the listener without TLS.
+	Plaintext bool
+	// Dev serves the listener with an ephemeral self-signed certificate
+	// if no certificate is given.
+	Dev bool
+	// ClientCAFile and ClientAuth configure the verification of client
+	// certificates. If ClientAuth is not set, the listener uses the
+	// server's settings.
+	ClientCAFile string
+	ClientAuth   string
+}
+
+// mainEndpoint returns the endpoint of the server's combined listener.
+func (s *Server) mainEndpoint() Endpoint {
+	return Endpoint{
+		Address:      s.Address,
+		CertFile:     s.CertFile,
+		KeyFile:      s.KeyFile,
+		Plaintext:    s.Plaintext,
+		Dev:          s.Dev,
+		ClientCAFile: s.ClientCAFile,
+		ClientAuth:   s.ClientAuth,
+	}
+}
+
+// inherit returns e, with the server's TLS settings if it has none of
+// its own, and with the server's client certificate settings if it has
+// none of its own.
+func (s *Server) inherit(e Endpoint) Endpoint {
+	if e.CertFile == "" && e.KeyFile == "" && !e.Plaintext {
+		e.CertFile, e.KeyFile, e.Plaintext = s.CertFile, s.KeyFile, s.Plaintext
+	}
+	e.Dev = e.Dev || s.Dev
+	if e.ClientAuth == "" {
+		e.ClientCAFile, e.ClientAuth = s.ClientCAFile, s.ClientAuth
+	}
+	return e
+}
+
+// listener is an http server with its listening socket.
+type listener struct {
+	Endpoint
+	name string
+	srv  *http.Server
+	conn net.Listener
+	// certs holds the listener's key pair and CA pool. The key pair is
+	// also presented by the REST gateway when it dials the listener.
+	certs *certReloader
+}
+
+// newListener loads the TLS material of an endpoint and listens on its
+// address. The listener does not accept connections until serve is called.
+func newListener(name string, e Endpoint) (*listener, error) {
+	l := &listener{Endpoint: e, name: name, srv: &http.Server{Addr: e.Address}}
+
+	if e.Plaintext {
+		// Serve prior-knowledge h2c, which is what gRPC clients use, next to
+		// HTTP/1.1. The connections stay owned by the http server so they
+		// are drained on shutdown.
+		l.srv.Protocols = new(http.Protocols)
+		l.srv.Protocols.SetHTTP1(true)
+		l.srv.Protocols.SetUnencryptedHTTP2(true)
+	} else {
+		certs, err := e.createCerts()
+		if err != nil {
+			return nil, err
+		}
+		clientAuth, err := parseClientAuth(e.ClientAuth)
+		if err != nil {
+			return nil, err
+		}
+		clientCAs, err := e.createClientCAs(clientAuth)
+		if err != nil {
+			return nil, err
+		}
+		l.certs = certs
+		l.srv.TLSConfig = &tls.Config{
+			GetCertificate: certs.GetCertificate,
+			NextProtos:     []string{"h2"},
+			ClientAuth:     clientAuth,
+			ClientCAs:      clientCAs,
+		}
+	}
+
+	conn, err := listenAddress(e.Address)
+	if err != nil {
+		return nil, &ListenError{Address: e.Address, Err: err}
+	}
+	if !e.Plaintext {
+		conn = tls.NewListener(conn, l.srv.TLSConfig)
+	}
+	l.conn = conn
+	return l, nil
+}
+
+// serve serves handler on the listener in the background. If serving
+// fails, the error is sent on errs.
+func (l *listener) serve(handler http.Handler, errs chan<- error) {
+	l.srv.Handler = handler
+	go func() {
+		err := l.srv.Serve(l.conn)
+		if err != http.ErrServerClosed {
+			errs <- &ListenError{Address: l.Address, Err: err}
+		}
+	}()
+	log.Printf("%s is ready on: %s", l.name, l.Address)
+}
+
+// dialAddress returns the address a gRPC client should dial to reach
+// the listener.
+func (l *listener) dialAddress() string {
+	return dialAddress(l.Address, l.conn)
+}
+
+// createCerts loads the endpoint's key pair, or creates an ephemeral one
+// in dev mode when no files are given.
+func (e Endpoint) createCerts() (*certReloader, error) {
+	if e.Dev && e.CertFile == "" && e.KeyFile == "" {
+		log.Printf("Serving %s with an ephemeral self-signed certificate", e.Address)
+		return newDevCertReloader(e)
+	}
+	if err := e.checkSecure(); err != nil {
+		return nil, err
+	}
+	return newCertReloader(e)
+}
+
+func (e Endpoint) checkSecure() error {
+	if e.CertFile == "" || e.KeyFile == "" {
+		return &TLSError{Err: ErrNoCredentials}
+	}
+	return nil
+}
+
+func (e Endpoint) createCertPool() (*x509.CertPool, error) {
+	cert, err := ioutil.ReadFile(e.CertFile)
+	if err != nil {
+		return nil, &TLSError{File: e.CertFile, Err: err}
+	}
+	certPool := x509.NewCertPool()
+	ok := certPool.AppendCertsFromPEM(cert)
+	if !ok {
+		return nil, &TLSError{File: e.CertFile, Err: ErrInvalidCert}
+	}
+	return certPool, nil
+}
+
+// createClientCAs loads the CAs that verify client certificates. Modes
+// that verify client certificates require a file, since the system roots
+// would accept any publicly trusted certificate.
+func (e Endpoint) createClientCAs(mode tls.ClientAuthType) (*x509.CertPool, error) {
+	if e.ClientCAFile == "" {
+		if mode == tls.VerifyClientCertIfGiven || mode == tls.RequireAndVerifyClientCert {
+			return nil, &TLSError{Err: fmt.Errorf("%w: client authentication mode %q", ErrNoClientCA, e.ClientAuth)}
+		}
+		return nil, nil
+	}
+	pem, err := ioutil.ReadFile(e.ClientCAFile)
+	if err != nil {
+		return nil, &TLSError{File: e.ClientCAFile, Err: err}
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, &TLSError{File: e.ClientCAFile, Err: ErrInvalidCert}
+	}
+	return pool, nil
+}
+
+// parseClientAuth parses a client authentication mode:
+//
+//	none:    client certificates are not requested.
+//	request: a client certificate is requested, and verified if given.
+//	require: a client certificate is required, but not verified.
+//	verify:  a verified client certificate is required.
+//
+// Only verified client certificates identify the caller.
+func parseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAnyClientCert, nil
+	case "verify":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, &TLSError{Err: fmt.Errorf("%w: %q", ErrInvalidClientAuth, mode)}
+	}
+}
+
+func (e Endpoint) createCertificate() (tls.Certificate, error) {
+	keyPair, err := tls.LoadX509KeyPair(e.CertFile, e.KeyFile)
+	if err != nil {
+		return tls.Certificate{}, &TLSError{File: e.CertFile + ", " + e.KeyFile, Err: err}
+	}
+	return keyPair, nil
+}
@@ -0,0 +1,261 @@
+package grpcgw
+
+import (
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/justinas/alice"
+	"github.com/posener/grpcgw/auth"
+	"github.com/posener/grpcgw/metrics"
+	"github.com/posener/grpcgw/middleware"
+	"github.com/posener/grpcgw/ratelimit"
+	"github.com/posener/grpcgw/tracing"
+	"google.golang.org/grpc"
+)
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithServices adds services to the server.
+func WithServices(services ...Service) Option {
+	return func(s *Server) {
+		s.AddService(services...)
+	}
+}
+
+// WithAddress sets the address the server listens on.
+func WithAddress(address string) Option {
+	return func(s *Server) {
+		s.Address = address
+	}
+}
+
+// WithTLS sets the certificate and private key files of the server.
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		s.CertFile = certFile
+		s.KeyFile = keyFile
+	}
+}
+
+// WithDev serves with an ephemeral self-signed certificate when no
+// certificate is given.
+func WithDev() Option {
+	return func(s *Server) {
+		s.Dev = true
+	}
+}
+
+// WithClientAuth sets the client certificate authentication mode of the
+// server, and a file of CAs that verify client certificates.
+func WithClientAuth(mode, caFile string) Option {
+	return func(s *Server) {
+		s.ClientAuth = mode
+		s.ClientCAFile = caFile
+	}
+}
+
+// WithPlaintext serves the server without TLS, over HTTP/1.1 and h2c.
+func WithPlaintext() Option {
+	return func(s *Server) {
+		s.Plaintext = true
+	}
+}
+
+// WithGRPCEndpoint serves gRPC on a separate listener.
+func WithGRPCEndpoint(e Endpoint) Option {
+	return func(s *Server) {
+		s.GRPC = e
+	}
+}
+
+// WithHTTPEndpoint serves REST on a separate listener.
+func WithHTTPEndpoint(e Endpoint) Option {
+	return func(s *Server) {
+		s.HTTP = e
+	}
+}
+
+// WithAdminEndpoint serves the administrative endpoints on a separate
+// listener.
+func WithAdminEndpoint(e Endpoint) Option {
+	return func(s *Server) {
+		s.Admin = e
+	}
+}
+
+// WithReflection registers the gRPC reflection service, restricted by
+// an access mode: ReflectionAll, ReflectionAuthenticated or ReflectionAdmin.
+func WithReflection(access string) Option {
+	return func(s *Server) {
+		s.Reflection = true
+		s.ReflectionAccess = access
+	}
+}
+
+// WithAdminIdentities sets the identities of admin callers.
+func WithAdminIdentities(names ...string) Option {
+	return func(s *Server) {
+		s.AdminIdentities = append(s.AdminIdentities, names...)
+	}
+}
+
+// WithMetrics records metrics of the server's traffic.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Server) {
+		s.Metrics = m
+	}
+}
+
+// WithTracing traces the server's traffic.
+func WithTracing(t *tracing.Tracing) Option {
+	return func(s *Server) {
+		s.Tracing = t
+	}
+}
+
+// WithJWT authenticates the server's callers with JWT bearer tokens.
+func WithJWT(j *auth.JWT) Option {
+	return func(s *Server) {
+		s.JWT = j
+	}
+}
+
+// WithAPIKeys authenticates the server's callers with API keys.
+func WithAPIKeys(a *auth.APIKeys) Option {
+	return func(s *Server) {
+		s.APIKeys = a
+	}
+}
+
+// WithCertificateAuthentication authenticates the server's callers with
+// verified client certificates.
+func WithCertificateAuthentication() Option {
+	return func(s *Server) {
+		s.CertificateAuthentication = true
+	}
+}
+
+// WithPublicMethods sets the gRPC methods that are open to unauthenticated
+// callers.
+func WithPublicMethods(methods ...string) Option {
+	return func(s *Server) {
+		s.PublicMethods = append(s.PublicMethods, methods...)
+	}
+}
+
+// WithPolicy authorizes the server's callers by their roles.
+func WithPolicy(p *auth.Policy) Option {
+	return func(s *Server) {
+		s.Policy = p
+	}
+}
+
+// WithRateLimit limits the rate of the server's REST and gRPC calls.
+func WithRateLimit(l *ratelimit.Limiter) Option {
+	return func(s *Server) {
+		s.RateLimit = l
+	}
+}
+
+// WithRecovery recovers panics of gRPC methods and of REST handlers. The
+// REST recovery middleware is appended to the middleware chain, so
+// middleware appended before it, such as the API call logger, sees the
+// 500 response.
+func WithRecovery() Option {
+	return func(s *Server) {
+		s.Recover = true
+		s.Middleware = s.Middleware.Append(middleware.Recovery(s.onPanic))
+	}
+}
+
+// WithLimits bounds the time and the size of the server's calls.
+func WithLimits(l Limits) Option {
+	return func(s *Server) {
+		s.Limits = l
+	}
+}
+
+// WithGRPCOptions appends options to the gRPC server's options.
+func WithGRPCOptions(opts ...grpc.ServerOption) Option {
+	return func(s *Server) {
+		s.GRPCOptions = append(s.GRPCOptions, opts...)
+	}
+}
+
+// WithUnaryInterceptors appends interceptors to the chain of unary gRPC
+// interceptors.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) Option {
+	return func(s *Server) {
+		s.UnaryInterceptors = append(s.UnaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors appends interceptors to the chain of streaming
+// gRPC interceptors.
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) Option {
+	return func(s *Server) {
+		s.StreamInterceptors = append(s.StreamInterceptors, interceptors...)
+	}
+}
+
+// WithGatewayOptions appends options to the REST gateway mux's options.
+// An incoming header matcher replaces the header policy, but can't forge
+// the forwarded identity or request ID.
+func WithGatewayOptions(opts ...runtime.ServeMuxOption) Option {
+	return func(s *Server) {
+		s.GatewayOptions = append(s.GatewayOptions, opts...)
+	}
+}
+
+// WithHeaderPolicy sets the headers that the REST gateway forwards.
+func WithHeaderPolicy(p HeaderPolicy) Option {
+	return func(s *Server) {
+		s.Headers = p
+	}
+}
+
+// WithProblemErrors makes the REST gateway reply to errors with RFC 7807
+// problem details.
+func WithProblemErrors() Option {
+	return func(s *Server) {
+		s.ProblemErrors = true
+	}
+}
+
+// WithCORS allows cross-origin REST calls.
+func WithCORS(o middleware.CORSOptions) Option {
+	return func(s *Server) {
+		s.CORS = o
+	}
+}
+
+// WithGatewayLoopback makes the REST gateway dial the server's address over
+// the network instead of using an in-process connection.
+func WithGatewayLoopback() Option {
+	return func(s *Server) {
+		s.GatewayLoopback = true
+	}
+}
+
+// WithSwaggers sets a directory containing swagger files to serve.
+func WithSwaggers(path string) Option {
+	return func(s *Server) {
+		s.SwaggersPath = path
+	}
+}
+
+// WithMiddleware appends HTTP middleware to the server's middleware chain.
+func WithMiddleware(constructors ...alice.Constructor) Option {
+	return func(s *Server) {
+		s.Middleware = s.Middleware.Append(constructors...)
+	}
+}
+
+// WithShutdownTimeout sets the maximal time to wait for in-flight requests
+// when the server is stopped.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.ShutdownTimeout = timeout
+	}
+}
@@ -0,0 +1,157 @@
+package grpcgw
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/posener/grpcgw/middleware"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// problem is an RFC 7807 problem details object, extended with the gRPC
+// status of the error.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"requestId,omitempty"`
+	// InvalidParams are the field violations of a BadRequest detail.
+	InvalidParams []invalidParam `json:"invalidParams,omitempty"`
+	// ErrorInfo is the ErrorInfo detail.
+	ErrorInfo *errorInfo `json:"errorInfo,omitempty"`
+}
+
+type invalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+type errorInfo struct {
+	Reason   string            `json:"reason"`
+	Domain   string            `json:"domain,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// problemStatus maps a gRPC code to the HTTP status of a problem. It is
+// the mapping of grpc-gateway:
+//
+//	OK                  200 OK
+//	Canceled            408 Request Timeout
+//	Unknown             500 Internal Server Error
+//	InvalidArgument     400 Bad Request
+//	DeadlineExceeded    504 Gateway Timeout
+//	NotFound            404 Not Found
+//	AlreadyExists       409 Conflict
+//	PermissionDenied    403 Forbidden
+//	Unauthenticated     401 Unauthorized
+//	ResourceExhausted   429 Too Many Requests
+//	FailedPrecondition  400 Bad Request
+//	Aborted             409 Conflict
+//	OutOfRange          400 Bad Request
+//	Unimplemented       501 Not Implemented
+//	Internal            500 Internal Server Error
+//	Unavailable         503 Service Unavailable
+//	DataLoss            500 Internal Server Error
+func problemStatus(code codes.Code) int {
+	return runtime.HTTPStatusFromCode(code)
+}
+
+// problemErrorHandler returns a gateway error handler that replies with
+// an RFC 7807 application/problem+json body. Response metadata is
+// forwarded as headers by the header policy. The messages of server
+// errors may hold internal details, so they are logged instead of being
+// written as the detail.
+func problemErrorHandler(headers HeaderPolicy) runtime.ProtoErrorHandlerFunc {
+	return func(ctx context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+		if err == runtime.ErrUnknownURI {
+			// The gateway mux reports unmatched routes as unimplemented.
+			err = status.Error(codes.NotFound, http.StatusText(http.StatusNotFound))
+		}
+		st := status.Convert(err)
+		httpStatus := problemStatus(st.Code())
+		if middleware.BodyTooLarge(r) {
+			httpStatus = http.StatusRequestEntityTooLarge
+		}
+		p := problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(httpStatus),
+			Status:   httpStatus,
+			Instance: r.URL.Path,
+			Code:     problemCode(st.Code()),
+		}
+		if httpStatus >= http.StatusInternalServerError {
+			middleware.Logf(r.Context(), "REST call failed: code=%s message=%q", p.Code, st.Message())
+		} else {
+			p.Detail = st.Message()
+		}
+		p.RequestID, _ = middleware.RequestIDFromContext(r.Context())
+		for _, detail := range st.Details() {
+			switch detail := detail.(type) {
+			case *errdetails.BadRequest:
+				for _, v := range detail.GetFieldViolations() {
+					p.InvalidParams = append(p.InvalidParams, invalidParam{Name: v.GetField(), Reason: v.GetDescription()})
+				}
+			case *errdetails.ErrorInfo:
+				p.ErrorInfo = &errorInfo{Reason: detail.GetReason(), Domain: detail.GetDomain(), Metadata: detail.GetMetadata()}
+			}
+		}
+
+		if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+			for key, values := range md.HeaderMD {
+				if name, ok := headers.matchOutgoing(key); ok {
+					for _, value := range values {
+						w.Header().Add(name, value)
+					}
+				}
+			}
+			for key, values := range md.TrailerMD {
+				if headers.matchTrailer(key) {
+					for _, value := range values {
+						w.Header().Add(key, value)
+					}
+				}
+			}
+		}
+		w.Header().Del("Trailer")
+		w.Header().Set("Content-Type", middleware.ProblemContentType)
+		w.WriteHeader(httpStatus)
+		if err := json.NewEncoder(w).Encode(p); err != nil {
+			middleware.Logf(r.Context(), "Failed writing problem response: %s", err)
+		}
+	}
+}
+
+// problemCode returns the name of a gRPC code, such as "INVALID_ARGUMENT".
+func problemCode(code codes.Code) string {
+	if name, ok := codeNames[code]; ok {
+		return name
+	}
+	return code.String()
+}
+
+var codeNames = map[codes.Code]string{
+	codes.OK:                 "OK",
+	codes.Canceled:           "CANCELLED",
+	codes.Unknown:            "UNKNOWN",
+	codes.InvalidArgument:    "INVALID_ARGUMENT",
+	codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	codes.NotFound:           "NOT_FOUND",
+	codes.AlreadyExists:      "ALREADY_EXISTS",
+	codes.PermissionDenied:   "PERMISSION_DENIED",
+	codes.ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	codes.FailedPrecondition: "FAILED_PRECONDITION",
+	codes.Aborted:            "ABORTED",
+	codes.OutOfRange:         "OUT_OF_RANGE",
+	codes.Unimplemented:      "UNIMPLEMENTED",
+	codes.Internal:           "INTERNAL",
+	codes.Unavailable:        "UNAVAILABLE",
+	codes.DataLoss:           "DATA_LOSS",
+	codes.Unauthenticated:    "UNAUTHENTICATED",
+}
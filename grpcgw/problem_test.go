@@ -0,0 +1,50 @@
+package grpcgw
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestProblemErrorHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{name: "invalid argument", err: status.Error(codes.InvalidArgument, "invalid name"), wantStatus: http.StatusBadRequest, wantCode: "INVALID_ARGUMENT", wantDetail: "invalid name"},
+		{name: "not found", err: status.Error(codes.NotFound, "no such user"), wantStatus: http.StatusNotFound, wantCode: "NOT_FOUND", wantDetail: "no such user"},
+		{name: "internal", err: status.Error(codes.Internal, "pq: password authentication failed for user admin"), wantStatus: http.StatusInternalServerError, wantCode: "INTERNAL"},
+		{name: "unknown", err: status.Error(codes.Unknown, "dial tcp 10.0.0.1:5432"), wantStatus: http.StatusInternalServerError, wantCode: "UNKNOWN"},
+		{name: "data loss", err: status.Error(codes.DataLoss, "corrupt row 17"), wantStatus: http.StatusInternalServerError, wantCode: "DATA_LOSS"},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "connection refused"), wantStatus: http.StatusServiceUnavailable, wantCode: "UNAVAILABLE"},
+	}
+	handler := problemErrorHandler(HeaderPolicy{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/v1/test", nil)
+			handler(context.Background(), nil, nil, rec, r, tt.err)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var p problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatalf("failed decoding problem: %s", err)
+			}
+			if p.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", p.Code, tt.wantCode)
+			}
+			if p.Detail != tt.wantDetail {
+				t.Errorf("detail = %q, want %q", p.Detail, tt.wantDetail)
+			}
+		})
+	}
+}
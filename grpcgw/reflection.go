@@ -0,0 +1,63 @@
+package grpcgw
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/posener/grpcgw/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Reflection access modes.
+const (
+	// ReflectionAll allows reflection to every caller.
+	ReflectionAll = "all"
+	// ReflectionAuthenticated allows reflection to callers authenticated by
+	// a JWT, an API key or, with certificate authentication, a client
+	// certificate.
+	ReflectionAuthenticated = "authenticated"
+	// ReflectionAdmin allows reflection to the server's admin identities.
+	ReflectionAdmin = "admin"
+)
+
+// reflectionPrefixes are the method prefixes of the reflection services.
+var reflectionPrefixes = []string{
+	"/grpc.reflection.v1.ServerReflection/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
+// reflectionGuard returns an interceptor that restricts the reflection
+// service to callers allowed by the access mode. Verified client
+// certificates authenticate callers only if certAuthn is set. Other
+// methods are not affected.
+func reflectionGuard(access string, admins []string, certAuthn bool) (grpc.StreamServerInterceptor, error) {
+	switch access {
+	case "", ReflectionAll, ReflectionAuthenticated, ReflectionAdmin:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidReflectionAccess, access)
+	}
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !isReflection(info.FullMethod) {
+			return handler(srv, stream)
+		}
+		id, ok := auth.FromContext(stream.Context())
+		switch {
+		case access == ReflectionAuthenticated && !(auth.Authenticated(stream.Context()) || certAuthn && ok):
+			return status.Error(codes.Unauthenticated, "reflection requires an authenticated caller")
+		case access == ReflectionAdmin && !(ok && id.MatchesAny(admins)):
+			return status.Error(codes.PermissionDenied, "reflection is restricted to admin callers")
+		}
+		return handler(srv, stream)
+	}, nil
+}
+
+func isReflection(method string) bool {
+	for _, prefix := range reflectionPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
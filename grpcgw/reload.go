@@ -0,0 +1,165 @@
+package grpcgw
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// certReloadInterval is the interval in which certificate files are
+// checked for changes.
+const certReloadInterval = 10 * time.Second
+
+// certReloader holds a key pair and the CA pool of a listener, and reloads
+// them when their files change or when the process gets SIGHUP.
+// If the new files are invalid, the old key pair is kept.
+type certReloader struct {
+	Endpoint
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	certPool    *x509.CertPool
+	modTime     time.Time
+}
+
+// newCertReloader loads the key pair and CA pool of an endpoint.
+func newCertReloader(e Endpoint) (*certReloader, error) {
+	r := &certReloader{Endpoint: e}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// newDevCertReloader holds an ephemeral self-signed key pair for an
+// endpoint. It is never reloaded.
+func newDevCertReloader(e Endpoint) (*certReloader, error) {
+	certificate, certPool, err := devCertificate(e.Address)
+	if err != nil {
+		return nil, &TLSError{Err: err}
+	}
+	return &certReloader{Endpoint: e, certificate: &certificate, certPool: certPool}, nil
+}
+
+// reload loads the endpoint's key pair and CA pool, and replaces the
+// current ones if they are valid.
+func (r *certReloader) reload() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+	certPool, err := r.createCertPool()
+	if err != nil {
+		return err
+	}
+	certificate, err := r.createCertificate()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certificate = &certificate
+	r.certPool = certPool
+	r.modTime = modTime
+	return nil
+}
+
+// filesModTime returns the latest modification time of the key pair files.
+func (r *certReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.CertFile, r.KeyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, &TLSError{File: file, Err: err}
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// changed reports whether the key pair files changed since the last
+// successful reload.
+func (r *certReloader) changed() bool {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !modTime.Equal(r.modTime)
+}
+
+// watch reloads the key pair when its files change or when the process
+// gets SIGHUP, until ctx is done.
+func (r *certReloader) watch(ctx context.Context) {
+	if r.CertFile == "" {
+		return
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(certReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+		}
+		if err := r.reload(); err != nil {
+			log.Printf("Failed reloading certificate, keeping the old one: %s", err)
+			continue
+		}
+		log.Printf("Reloaded certificate %s", r.CertFile)
+	}
+}
+
+// GetCertificate returns the current key pair. It is used as the listener's
+// tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.certificate, nil
+}
+
+// GetClientCertificate returns the current key pair. It is presented by the
+// REST gateway when it dials the listener.
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.certificate, nil
+}
+
+// VerifyConnection verifies the server certificate against the current CA
+// pool. It is used by the REST gateway when it dials the listener, so it
+// trusts a reloaded CA without redialing.
+func (r *certReloader) VerifyConnection(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	r.mu.RLock()
+	roots := r.certPool
+	r.mu.RUnlock()
+
+	opts := x509.VerifyOptions{DNSName: state.ServerName, Roots: roots, Intermediates: x509.NewCertPool()}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(opts)
+	return err
+}
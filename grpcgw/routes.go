@@ -0,0 +1,135 @@
+package grpcgw
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/protoc-gen-grpc-gateway/httprule"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// route is a route of the REST gateway.
+type route struct {
+	method  string
+	pattern runtime.Pattern
+}
+
+// gatewayRoutes returns the REST gateway routes of gRPC services. They are
+// built from the google.api.http annotations of the services' methods, the
+// same way the generated gateway code builds the routes it registers.
+// Services whose descriptors are not registered have no routes.
+func gatewayRoutes(services map[string]grpc.ServiceInfo) []route {
+	var routes []route
+	for name := range services {
+		desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			continue
+		}
+		service, ok := desc.(protoreflect.ServiceDescriptor)
+		if !ok {
+			continue
+		}
+		methods := service.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			rule, ok := proto.GetExtension(methods.Get(i).Options(), annotations.E_Http).(*annotations.HttpRule)
+			if !ok || rule == nil {
+				continue
+			}
+			routes = append(routes, httpRoutes(rule)...)
+		}
+	}
+	return routes
+}
+
+// httpRoutes returns the routes of an http rule and of its additional
+// bindings.
+func httpRoutes(rule *annotations.HttpRule) []route {
+	var method, path string
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		method, path = "GET", p.Get
+	case *annotations.HttpRule_Put:
+		method, path = "PUT", p.Put
+	case *annotations.HttpRule_Post:
+		method, path = "POST", p.Post
+	case *annotations.HttpRule_Delete:
+		method, path = "DELETE", p.Delete
+	case *annotations.HttpRule_Patch:
+		method, path = "PATCH", p.Patch
+	case *annotations.HttpRule_Custom:
+		method, path = p.Custom.GetKind(), p.Custom.GetPath()
+	}
+	var routes []route
+	if pattern, err := parsePattern(path); err != nil {
+		log.Printf("Failed parsing route: method=%s path=%s err=%s", method, path, err)
+	} else {
+		routes = append(routes, route{method: method, pattern: pattern})
+	}
+	for _, binding := range rule.GetAdditionalBindings() {
+		routes = append(routes, httpRoutes(binding)...)
+	}
+	return routes
+}
+
+// parsePattern parses a path template of an http rule.
+func parsePattern(path string) (runtime.Pattern, error) {
+	compiler, err := httprule.Parse(path)
+	if err != nil {
+		return runtime.Pattern{}, err
+	}
+	t := compiler.Compile()
+	return runtime.NewPattern(t.Version, t.OpCodes, t.Pool, t.Verb, runtime.AssumeColonVerbOpt(true))
+}
+
+// routed returns a function that reports whether a REST call matches a
+// route of the gateway, by its path and method.
+func routed(routes []route) func(r *http.Request) bool {
+	methods := routeMethods(routes)
+	return func(r *http.Request) bool {
+		for _, method := range methods(r.URL.Path) {
+			if method == r.Method {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// routeMethods returns a function that returns the methods of the routes
+// that match a path, the same way the gateway mux matches them.
+func routeMethods(routes []route) func(path string) []string {
+	return func(path string) []string {
+		if !strings.HasPrefix(path, "/") {
+			return nil
+		}
+		components := strings.Split(path[1:], "/")
+		last := len(components) - 1
+		var verb string
+		if i := strings.LastIndex(components[last], ":"); i == 0 {
+			return nil
+		} else if i > 0 {
+			components[last], verb = components[last][:i], components[last][i+1:]
+		}
+
+		seen := map[string]bool{}
+		var methods []string
+		for _, r := range routes {
+			if seen[r.method] {
+				continue
+			}
+			if _, err := r.pattern.Match(components, verb); err == nil {
+				seen[r.method] = true
+				methods = append(methods, r.method)
+			}
+		}
+		sort.Strings(methods)
+		return methods
+	}
+}
@@ -0,0 +1,71 @@
+package grpcgw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+func TestRouteMethods(t *testing.T) {
+	var routes []route
+	for _, rule := range []*annotations.HttpRule{
+		{Pattern: &annotations.HttpRule_Get{Get: "/v1/users/{id}"}, AdditionalBindings: []*annotations.HttpRule{
+			{Pattern: &annotations.HttpRule_Delete{Delete: "/v1/users/{id}"}},
+		}},
+		{Pattern: &annotations.HttpRule_Post{Post: "/v1/users"}},
+		{Pattern: &annotations.HttpRule_Post{Post: "/v1/users/{id}:disable"}},
+		{Pattern: &annotations.HttpRule_Get{Get: "/v1/files/{path=**}"}},
+		{Pattern: &annotations.HttpRule_Patch{Patch: "/v1/{name=projects/*/items/*}"}},
+	} {
+		routes = append(routes, httpRoutes(rule)...)
+	}
+	methods := routeMethods(routes)
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{path: "/v1/users", want: []string{"POST"}},
+		{path: "/v1/users/42", want: []string{"DELETE", "GET"}},
+		{path: "/v1/users/42:disable", want: []string{"POST"}},
+		{path: "/v1/users/42:enable"},
+		{path: "/v1/files/a/b/c.txt", want: []string{"GET"}},
+		{path: "/v1/projects/p/items/i", want: []string{"PATCH"}},
+		{path: "/v1/projects/p/items"},
+		{path: "/v2/users"},
+		{path: "/v1/users/:disable"},
+		{path: "v1/users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := methods(tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("methods(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouted(t *testing.T) {
+	routes := httpRoutes(&annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/v1/users/{id}"}})
+	isRouted := routed(routes)
+
+	tests := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{method: http.MethodGet, path: "/v1/users/42", want: true},
+		{method: http.MethodPost, path: "/v1/users/42", want: false},
+		{method: http.MethodGet, path: "/swagger-ui/index.html", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if got := isRouted(httptest.NewRequest(tt.method, tt.path, nil)); got != tt.want {
+				t.Errorf("routed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package grpcgw
 
 import (
 	"crypto/tls"
+	"fmt"
 	"log"
 	"mime"
 	"net"
@@ -9,94 +10,595 @@ import (
 	"strings"
 
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/posener/grpcgw/auth"
+	"github.com/posener/grpcgw/metrics"
+	"github.com/posener/grpcgw/middleware"
+	"github.com/posener/grpcgw/ratelimit"
+	"github.com/posener/grpcgw/tracing"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
-
-	"crypto/x509"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/justinas/alice"
-	"io/ioutil"
 	"path/filepath"
+	"sync"
+	"time"
+
 	"github.com/philips/go-bindata-assetfs"
 )
 
-type server struct {
-	Service
-	Address    string
-	Middleware alice.Chain
-	Swagger    map[string]string
-	KeyFile    string
-	CertFile   string
+const (
+	// inProcessAddress is the address the gateway dials when it reaches the
+	// gRPC server in-process. It is not resolved.
+	inProcessAddress = "passthrough:///grpcgw.in-process"
+	// inProcessBufferSize is the buffer size of the in-process connection.
+	inProcessBufferSize = 1 << 20
+)
+
+// Server serves services over gRPC and over a REST gateway, by default on
+// the same address.
+type Server struct {
+	Services []Service
+	// Address is a "host:port", a "unix://<path>" or a systemd socket
+	// activation "fd://[<name>|<number>]" address.
+	Address      string
+	Middleware   alice.Chain
+	Swagger      map[string]string
+	KeyFile      string
+	CertFile     string
 	SwaggersPath string
+	// Plaintext serves gRPC and REST without TLS, over HTTP/1.1 and
+	// HTTP/2 cleartext (h2c). It is meant for local development and for
+	// running behind a TLS-terminating proxy.
+	Plaintext bool
+	// Dev serves with an ephemeral self-signed certificate when no
+	// certificate is given. It is meant for local development.
+	Dev bool
+	// ClientCAFile is a file of CAs that verify client certificates. It is
+	// required by the "request" and "verify" client authentication modes.
+	ClientCAFile string
+	// ClientAuth is the client certificate authentication mode: "none",
+	// "request", "require" or "verify". The identity of callers with a
+	// verified certificate is available to REST handlers and to gRPC
+	// methods with auth.FromContext.
+	ClientAuth string
+	// GRPC, HTTP and Admin configure optional separate listeners for gRPC
+	// traffic, REST traffic and administrative endpoints, such as the
+	// swagger-ui. Unless both GRPC and HTTP listeners are configured, gRPC
+	// and REST are also served on the server's Address.
+	GRPC  Endpoint
+	HTTP  Endpoint
+	Admin Endpoint
+	// Reflection registers the gRPC server reflection service, for all the
+	// registered services. ReflectionAccess restricts it to "all",
+	// "authenticated" or "admin" callers. Admin callers are those whose
+	// identity matches one of AdminIdentities.
+	Reflection       bool
+	ReflectionAccess string
+	AdminIdentities  []string
+	// Metrics records metrics of the REST and gRPC traffic, and serves them
+	// on /metrics of the admin listener, or of the REST listener if there
+	// is no admin listener. Its Origin is set by the server.
+	Metrics *metrics.Metrics
+	// Tracing creates spans for REST and gRPC calls, and propagates the
+	// trace context of REST calls through the REST gateway.
+	Tracing *tracing.Tracing
+	// JWT authenticates gRPC calls, and REST calls through the gateway,
+	// with JWT bearer tokens. The validated claims are available to gRPC
+	// methods with auth.ClaimsFromContext.
+	JWT *auth.JWT
+	// APIKeys authenticates gRPC calls, and REST calls through the
+	// gateway, with API keys. The validated key is available to gRPC
+	// methods with auth.APIKeyFromContext.
+	APIKeys *auth.APIKeys
+	// CertificateAuthentication authenticates callers with a verified
+	// client certificate, next to JWT and API keys. Client certificates
+	// are verified by ClientAuth and ClientCAFile.
+	CertificateAuthentication bool
+	// PublicMethods are the gRPC methods that are open to unauthenticated
+	// callers. A trailing "*" matches a prefix. If nil,
+	// auth.DefaultPublicMethods are public.
+	PublicMethods []string
+	// Policy authorizes gRPC calls, and REST calls through the gateway, by
+	// the roles of the callers. Public methods are not authorized.
+	Policy *auth.Policy
+	// RateLimit limits the rate of REST calls by their path and of gRPC
+	// calls by their method. REST calls served by the gateway are limited
+	// only by the RPC they invoke. Its Forwarded, Routed and Reject are set
+	// by the server.
+	RateLimit *ratelimit.Limiter
+	// Recover turns panics of gRPC methods into Internal errors, and logs
+	// them. Panics of REST handlers are recovered by middleware.Recovery
+	// in the Middleware chain, which WithRecovery installs.
+	Recover bool
+	// GRPCOptions are additional options of the gRPC server, such as
+	// message size limits. They are applied after the server's own
+	// options, so they can override them.
+	GRPCOptions []grpc.ServerOption
+	// UnaryInterceptors and StreamInterceptors are chained after the
+	// server's own interceptors, in order, so the caller's identity is
+	// already in their context.
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+	// GatewayOptions are additional options of the REST gateway mux, such
+	// as marshalers, header matchers and error handlers. They are applied
+	// after the server's own options, so they can override them. A header
+	// matcher can't forge the forwarded identity or request ID: the
+	// gateway replaces them after the headers are matched.
+	GatewayOptions []runtime.ServeMuxOption
+	// Headers selects the headers that the REST gateway forwards between
+	// REST callers and gRPC methods.
+	Headers HeaderPolicy
+	// ProblemErrors makes the REST gateway reply to errors with RFC 7807
+	// application/problem+json bodies, which render the status details.
+	ProblemErrors bool
+	// CORS allows cross-origin REST calls from its AllowedOrigins, if there
+	// are any. The methods allowed on a path are those of the gateway
+	// routes that match it, by the google.api.http annotations of the
+	// services.
+	CORS middleware.CORSOptions
+	// Limits bound the time and the size of REST and gRPC calls.
+	Limits Limits
+	// GatewayLoopback makes the REST gateway dial the server's address over
+	// the network. By default the gateway reaches the gRPC server through
+	// an in-process connection.
+	GatewayLoopback bool
+	// ShutdownTimeout is the maximal time to wait for in-flight requests
+	// to finish after the serving context is done.
+	ShutdownTimeout time.Duration
+
+	// gateway forwards the identities of REST callers to the gRPC server.
+	gateway *auth.Gateway
+	// health reports the health of the server and its services.
+	health *healthReporter
+	// routes are the routes of the REST gateway, by the http annotations of
+	// the registered gRPC services.
+	routes []route
+}
+
+// NewServer creates a server for a service, configured by the given options.
+// More services can be added with the WithServices option or with the
+// AddService method.
+func NewServer(service Service, opts ...Option) *Server {
+	s := &Server{
+		Services:        []Service{service},
+		Address:         defaultAddress,
+		Middleware:      alice.Chain{},
+		ShutdownTimeout: defaultShutdownTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func NewServer(service Service) *server {
-	return &server{Service: service, Middleware: alice.Chain{}}
+// AddService adds services to the server. All services are registered on
+// the same gRPC server and the same REST gateway when the server runs.
+func (s *Server) AddService(services ...Service) {
+	s.Services = append(s.Services, services...)
 }
 
-func Serve(s *server, ctx context.Context) {
-	s.checkSecure()
+// Serve runs the server and exits the process if it fails.
+//
+// Deprecated: use Server.Run, which returns an error instead.
+func Serve(s *Server, ctx context.Context) {
+	if err := s.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	var err error
-	certPool := s.createCertPool()
+// Run serves the server until ctx is done, and then gracefully stops it.
+// It returns a *TLSError, a *ListenError, a *RegisterError or
+// ErrInvalidReflectionAccess if the server could not be started.
+func (s *Server) Run(ctx context.Context) error {
+	listeners, err := s.listen()
+	if err != nil {
+		return err
+	}
+	main, grpcListener, httpListener, adminListener := listeners[0], listeners[1], listeners[2], listeners[3]
 
-	mainMux := http.NewServeMux()
-	grpcHandler := createGrpcHandler(s, certPool)
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	for _, l := range listeners {
+		if l != nil && l.certs != nil {
+			go l.certs.watch(watchCtx)
+		}
+	}
 
+	s.gateway = auth.NewGateway()
+	s.health = newHealthReporter()
+	if s.Metrics != nil {
+		s.Metrics.Origin = s.origin
+	}
+	if s.RateLimit != nil {
+		s.RateLimit.Forwarded = s.gateway.FromGateway
+		if s.ProblemErrors {
+			s.RateLimit.Reject = func(w http.ResponseWriter, r *http.Request, err error) {
+				problemErrorHandler(s.Headers)(r.Context(), nil, nil, w, r, err)
+			}
+		}
+	}
+	if s.APIKeys != nil {
+		go s.APIKeys.Watch(watchCtx)
+	}
+	grpcHandler, err := createGrpcHandler(s)
+	if err != nil {
+		closeListeners(listeners)
+		return err
+	}
+	go s.health.watch(watchCtx)
+	if s.RateLimit != nil {
+		s.RateLimit.Routed = routed(s.routes)
+	}
+
+	restMux := http.NewServeMux()
+	adminMux := restMux
+	if adminListener != nil {
+		adminMux = http.NewServeMux()
+	}
+
+	swaggers, err := handleSwaggerJson(s.SwaggersPath)
+	if err != nil {
+		closeListeners(listeners)
+		return err
+	}
 	prefix := "/swagger-ui/"
-	mainMux.Handle(prefix, http.StripPrefix(prefix, handleSwaggerUI()))
+	handle(adminMux, prefix, http.StripPrefix(prefix, handleSwaggerUI()))
 	prefix = "/swaggers/"
-	mainMux.Handle(prefix, http.StripPrefix(prefix, handleSwaggerJson(s.SwaggersPath)))
+	handle(adminMux, prefix, http.StripPrefix(prefix, swaggers))
+	if s.Metrics != nil {
+		handle(adminMux, "/metrics", s.Metrics.Handler())
+	}
 
-	mainMux.Handle("/", createGateway(s, ctx, certPool))
+	// Health endpoints are served for probes on the REST mux, and on the
+	// admin mux if it is separate.
+	s.health.handle(restMux)
+	if adminMux != restMux {
+		s.health.handle(adminMux)
+	}
+
+	var inProcess *bufconn.Listener
+	if !s.GatewayLoopback {
+		inProcess = bufconn.Listen(inProcessBufferSize)
+		go grpcHandler.Serve(inProcess)
+	}
 
-	conn, err := net.Listen("tcp", s.Address)
+	// In loopback mode, the gateway dials a listener that serves gRPC.
+	loopback := grpcListener
+	if loopback == nil {
+		loopback = main
+	}
+
+	// The gateway connections should outlive the serving context,
+	// so REST calls could be drained on shutdown.
+	gwCtx, gwCancel := context.WithCancel(context.Background())
+	defer gwCancel()
+	gateway, err := createGateway(s, gwCtx, loopback, inProcess)
 	if err != nil {
-		log.Panic(err)
+		grpcHandler.Stop()
+		closeListeners(listeners)
+		return err
 	}
+	restMux.Handle("/", gateway)
 
-	mainHandler := s.Middleware.Append(gatewayMiddleware(grpcHandler)).Then(mainMux)
+	errs := make(chan error, len(listeners))
+	if main != nil {
+		main.serve(s.Middleware.Append(gatewayMiddleware(grpcHandler)).Extend(s.restMiddleware()).Then(restMux), errs)
+	}
+	if grpcListener != nil {
+		grpcListener.serve(grpcHandler, errs)
+	}
+	if httpListener != nil {
+		httpListener.serve(s.Middleware.Extend(s.restMiddleware()).Then(restMux), errs)
+	}
+	if adminListener != nil {
+		adminListener.serve(s.Middleware.Extend(s.restMiddleware()).Then(adminMux), errs)
+	}
 
-	certificates := []tls.Certificate{s.createCertificate()}
-	tlsConfig := tls.Config{Certificates: certificates, NextProtos: []string{"h2"}}
-	srv := &http.Server{Addr: s.Address, Handler: mainHandler, TLSConfig: &tlsConfig}
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+	}
 
-	listener := tls.NewListener(conn, srv.TLSConfig)
-	err = srv.Serve(listener)
+	shutdown(s, listeners, grpcHandler)
+	return err
+}
 
-	if err != nil {
-		log.Panicf("ListenAndServe failed: %s", err)
+// restMiddleware returns the middleware that applies to REST calls, but
+// not to native gRPC calls.
+func (s *Server) restMiddleware() alice.Chain {
+	chain := alice.New(middleware.RequestID, auth.CertificateMiddleware)
+	if s.Limits.Body.MaxBytes > 0 || len(s.Limits.Body.Routes) > 0 {
+		chain = chain.Append(middleware.BodyLimit(s.Limits.Body))
 	}
+	if s.Tracing != nil {
+		chain = chain.Append(s.Tracing.Middleware)
+	}
+	if s.Metrics != nil {
+		chain = chain.Append(s.Metrics.Middleware)
+	}
+	if len(s.CORS.AllowedOrigins) > 0 {
+		cors := s.CORS
+		if cors.Methods == nil {
+			cors.Methods = routeMethods(s.routes)
+		}
+		chain = chain.Append(middleware.CORS(cors))
+	}
+	if s.APIKeys != nil {
+		chain = chain.Append(s.APIKeys.Middleware)
+	}
+	if s.RateLimit != nil {
+		chain = chain.Append(s.RateLimit.Middleware)
+	}
+	return chain
+}
 
-	log.Printf("Grpc is ready on: %s", s.Address)
-	go func() {
-		<- ctx.Done()
+// origin returns the origin of a gRPC call, for metrics.
+func (s *Server) origin(ctx context.Context) string {
+	if s.gateway.FromGateway(ctx) {
+		return metrics.OriginGateway
+	}
+	return metrics.OriginGRPC
+}
+
+// handle registers a handler on a mux, and labels its calls with the
+// pattern for metrics.
+func handle(mux *http.ServeMux, pattern string, handler http.Handler) {
+	mux.Handle(pattern, metrics.Route(pattern, handler))
+}
+
+// listen opens the server's listeners. It returns the combined, gRPC, REST
+// and admin listeners, in that order. Listeners that are not configured
+// are nil. The combined listener is opened unless both the gRPC and the
+// REST listeners are configured.
+func (s *Server) listen() ([]*listener, error) {
+	listeners := make([]*listener, 4)
+	endpoints := []struct {
+		name     string
+		endpoint Endpoint
+		enabled  bool
+	}{
+		{"Grpc", s.mainEndpoint(), s.GRPC.Address == "" || s.HTTP.Address == ""},
+		{"Grpc listener", s.inherit(s.GRPC), s.GRPC.Address != ""},
+		{"REST listener", s.inherit(s.HTTP), s.HTTP.Address != ""},
+		{"Admin listener", s.inherit(s.Admin), s.Admin.Address != ""},
+	}
+	var addresses []string
+	for _, e := range endpoints {
+		if e.enabled {
+			addresses = append(addresses, e.endpoint.Address)
+		}
+	}
+	if err := checkInherited(addresses); err != nil {
+		return nil, err
+	}
+	for i, e := range endpoints {
+		if !e.enabled {
+			continue
+		}
+		l, err := newListener(e.name, e.endpoint)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		s.Limits.apply(l.srv)
+		listeners[i] = l
+	}
+	return listeners, nil
+}
+
+func closeListeners(listeners []*listener) {
+	for _, l := range listeners {
+		if l != nil {
+			l.conn.Close()
+		}
+	}
+}
+
+// shutdown stops accepting new connections and waits for in-flight
+// REST and gRPC calls to finish. If they do not finish within the
+// server's ShutdownTimeout, the remaining connections are closed.
+func shutdown(s *Server, listeners []*listener, grpcHandler *grpc.Server) {
+	// Readiness flips before draining, so load balancers stop sending
+	// new requests.
+	s.health.shutdown()
+	log.Printf("Shutting down, draining for at most %s", s.ShutdownTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+
+	// The gRPC handler is served through the http servers, so its streams
+	// are drained by the http servers shutdown. The gRPC server must not be
+	// gracefully stopped while such streams are still active.
+	var wg sync.WaitGroup
+	failed := make(chan struct{}, len(listeners))
+	for _, l := range listeners {
+		if l == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(l *listener) {
+			defer wg.Done()
+			if err := l.srv.Shutdown(drainCtx); err != nil {
+				log.Printf("Drain deadline passed on %s, closing remaining connections: %s", l.Address, err)
+				l.srv.Close()
+				failed <- struct{}{}
+			}
+		}(l)
+	}
+	wg.Wait()
+	if len(failed) > 0 {
 		grpcHandler.Stop()
-		conn.Close()
-		listener.Close()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		grpcHandler.GracefulStop()
+		close(done)
 	}()
+	select {
+	case <-done:
+	case <-drainCtx.Done():
+		log.Print("Drain deadline passed, closing remaining gRPC streams")
+		grpcHandler.Stop()
+	}
+	log.Print("Server stopped")
+}
 
-	return
+// onPanic counts a recovered panic.
+func (s *Server) onPanic(transport string) {
+	if s.Metrics != nil {
+		s.Metrics.Panic(transport)
+	}
 }
 
-func createGrpcHandler(s *server, certPool *x509.CertPool) *grpc.Server {
-	options := []grpc.ServerOption{grpc.Creds(credentials.NewClientTLSFromCert(certPool, s.Address))}
-	grpcHandler := grpc.NewServer(options...)
-	s.RegisterGRPC(grpcHandler)
-	return grpcHandler
+// authenticators returns the authenticators of the server's callers.
+func (s *Server) authenticators() []auth.Authenticator {
+	var authenticators []auth.Authenticator
+	if s.JWT != nil {
+		authenticators = append(authenticators, s.JWT)
+	}
+	if s.APIKeys != nil {
+		authenticators = append(authenticators, s.APIKeys)
+	}
+	if s.CertificateAuthentication {
+		authenticators = append(authenticators, auth.Certificates{})
+	}
+	return authenticators
+}
+
+func createGrpcHandler(s *Server) (*grpc.Server, error) {
+	var (
+		unary  []grpc.UnaryServerInterceptor
+		stream []grpc.StreamServerInterceptor
+	)
+	unary = append(unary, middleware.RequestIDUnaryServerInterceptor())
+	stream = append(stream, middleware.RequestIDStreamServerInterceptor())
+	if s.Tracing != nil {
+		unary = append(unary, s.Tracing.UnaryServerInterceptor())
+		stream = append(stream, s.Tracing.StreamServerInterceptor())
+	}
+	unary = append(unary, s.gateway.UnaryServerInterceptor())
+	stream = append(stream, s.gateway.StreamServerInterceptor())
+	if s.Metrics != nil {
+		unary = append(unary, s.Metrics.UnaryServerInterceptor())
+		stream = append(stream, s.Metrics.StreamServerInterceptor())
+	}
+	// Recovery is inside tracing and metrics, so they see a recovered
+	// panic as an Internal error.
+	if s.Recover {
+		unary = append(unary, middleware.RecoveryUnaryServerInterceptor(s.onPanic))
+		stream = append(stream, middleware.RecoveryStreamServerInterceptor(s.onPanic))
+	}
+	if authenticators := s.authenticators(); len(authenticators) > 0 {
+		unary = append(unary, auth.UnaryServerInterceptor(s.PublicMethods, authenticators...))
+		stream = append(stream, auth.StreamServerInterceptor(s.PublicMethods, authenticators...))
+	}
+	if s.Policy != nil {
+		unary = append(unary, s.Policy.UnaryServerInterceptor(s.PublicMethods))
+		stream = append(stream, s.Policy.StreamServerInterceptor(s.PublicMethods))
+	}
+	if s.RateLimit != nil {
+		unary = append(unary, s.RateLimit.UnaryServerInterceptor())
+		stream = append(stream, s.RateLimit.StreamServerInterceptor())
+	}
+	if s.Reflection {
+		guard, err := reflectionGuard(s.ReflectionAccess, s.AdminIdentities, s.CertificateAuthentication)
+		if err != nil {
+			return nil, err
+		}
+		stream = append(stream, guard)
+	}
+	unary = append(unary, s.UnaryInterceptors...)
+	stream = append(stream, s.StreamInterceptors...)
+
+	// The handler is served either through the http server, which handles
+	// TLS, or through the in-process listener, which needs none.
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+	opts = append(opts, s.Limits.serverOptions()...)
+	grpcHandler := grpc.NewServer(append(opts, s.GRPCOptions...)...)
+	for _, service := range s.Services {
+		before := grpcHandler.GetServiceInfo()
+		service.RegisterGRPC(grpcHandler)
+		var names []string
+		for name := range grpcHandler.GetServiceInfo() {
+			if _, ok := before[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		s.health.track(service, names)
+	}
+	s.routes = gatewayRoutes(grpcHandler.GetServiceInfo())
+	healthpb.RegisterHealthServer(grpcHandler, s.health.server)
+	if s.Reflection {
+		reflection.Register(grpcHandler)
+	}
+	return grpcHandler, nil
 }
 
-func createGateway(s *server, ctx context.Context, certPool *x509.CertPool) http.Handler {
-	gwMux := runtime.NewServeMux()
+// createGateway creates the REST gateway handler. If inProcess is not nil,
+// the gateway reaches the gRPC server through it, otherwise it dials the
+// loopback listener.
+func createGateway(s *Server, ctx context.Context, loopback *listener, inProcess *bufconn.Listener) (http.Handler, error) {
+	muxOptions := s.Headers.gatewayOptions()
+	if s.APIKeys != nil {
+		muxOptions = append(muxOptions, runtime.WithMetadata(s.APIKeys.Metadata))
+	}
+	if s.ProblemErrors {
+		muxOptions = append(muxOptions, runtime.WithProtoErrorHandler(problemErrorHandler(s.Headers)))
+	}
+	gwMux := runtime.NewServeMux(append(muxOptions, s.GatewayOptions...)...)
 
-	dialCreds := credentials.NewTLS(&tls.Config{ServerName: s.Address, RootCAs: certPool})
-	dialOptions := []grpc.DialOption{grpc.WithTransportCredentials(dialCreds)}
-	err := s.RegisterGatewayEndpoints(ctx, gwMux, s.Address, dialOptions)
-	if err != nil {
-		log.Panicf("Failed registering: %v", err)
+	address := loopback.dialAddress()
+	var dialOptions []grpc.DialOption
+	switch {
+	case inProcess != nil:
+		address = inProcessAddress
+		dialOptions = append(dialOptions, grpc.WithInsecure(), grpc.WithContextDialer(
+			func(ctx context.Context, _ string) (net.Conn, error) {
+				return inProcess.DialContext(ctx)
+			}))
+	case loopback.Plaintext:
+		dialOptions = append(dialOptions, grpc.WithInsecure())
+	default:
+		// The gateway presents the listener's certificate, in case the
+		// listener requires client certificates. The server certificate is
+		// verified against the listener's current CA pool, which may be
+		// reloaded, instead of a fixed RootCAs.
+		dialCreds := credentials.NewTLS(&tls.Config{
+			ServerName:           serverName(address),
+			InsecureSkipVerify:   true,
+			VerifyConnection:     loopback.certs.VerifyConnection,
+			GetClientCertificate: loopback.certs.GetClientCertificate,
+		})
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(dialCreds))
 	}
-	return gwMux
+	dialOptions = append(dialOptions, s.Limits.dialOptions()...)
+	dialOptions = append(dialOptions,
+		grpc.WithChainUnaryInterceptor(s.gateway.UnaryClientInterceptor(), middleware.RequestIDUnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(s.gateway.StreamClientInterceptor(), middleware.RequestIDStreamClientInterceptor()),
+		grpc.WithChainUnaryInterceptor(metrics.GatewayUnaryInterceptor()),
+		grpc.WithChainStreamInterceptor(metrics.GatewayStreamInterceptor()),
+	)
+	if s.Tracing != nil {
+		dialOptions = append(dialOptions,
+			grpc.WithChainUnaryInterceptor(s.Tracing.GatewayUnaryInterceptor()),
+			grpc.WithChainStreamInterceptor(s.Tracing.GatewayStreamInterceptor()),
+		)
+	}
+	for _, service := range s.Services {
+		err := service.RegisterGatewayEndpoints(ctx, gwMux, address, dialOptions)
+		if err != nil {
+			return nil, &RegisterError{Service: service, Err: err}
+		}
+	}
+	return gwMux, nil
 }
 
 // construct a gateway middleware.
@@ -123,37 +625,10 @@ func handleSwaggerUI() http.Handler {
 	})
 }
 
-func handleSwaggerJson(swaggersPath string) http.Handler {
+func handleSwaggerJson(swaggersPath string) (http.Handler, error) {
 	path, err := filepath.Abs(swaggersPath)
 	if err != nil {
-		log.Panic("Failed calculating absoulute path of swagger directory")
+		return nil, fmt.Errorf("calculating absolute path of swagger directory %s: %s", swaggersPath, err)
 	}
-	return http.FileServer(http.Dir(path))
+	return http.FileServer(http.Dir(path)), nil
 }
-
-func (s *server)checkSecure() {
-	if s.CertFile == "" || s.KeyFile == "" {
-		log.Fatal("Must provide a key and certificate to run server")
-	}
-}
-
-func (s *server)createCertPool() *x509.CertPool{
-	cert, err := ioutil.ReadFile(s.CertFile)
-	if err != nil {
-		log.Fatalf("Failed reading cert from %s: %s", s.CertFile, err)
-	}
-	certPool := x509.NewCertPool()
-	ok := certPool.AppendCertsFromPEM(cert)
-	if !ok {
-		log.Panic("Could not add cert to certPool")
-	}
-	return certPool
-}
-
-func (s *server)createCertificate() tls.Certificate {
-	keyPair, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
-	if err != nil {
-		log.Panicf("Failed loading key-pair from files %s, %s: %s", s.CertFile, s.KeyFile, err)
-	}
-	return keyPair
-}
\ No newline at end of file
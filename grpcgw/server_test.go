@@ -0,0 +1,98 @@
+package grpcgw
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// slowService serves a unary method that returns after a delay, and
+// reports when calls start.
+type slowService struct {
+	testService
+	delay   time.Duration
+	started chan struct{}
+}
+
+func (s *slowService) RegisterGRPC(server *grpc.Server) {
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "test.Slow",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Wait",
+			Handler: func(_ interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(wrapperspb.StringValue)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					s.started <- struct{}{}
+					time.Sleep(s.delay)
+					return req, nil
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{FullMethod: "/test.Slow/Wait"}, handler)
+			},
+		}},
+	}, struct{}{})
+}
+
+// freeAddress returns a local address that is free to listen on.
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// TestRunShutdown tests that a gRPC call that is in flight when the
+// server's context is done is served before Run returns.
+func TestRunShutdown(t *testing.T) {
+	service := &slowService{delay: 500 * time.Millisecond, started: make(chan struct{}, 1)}
+	address := freeAddress(t)
+	s := NewServer(service, WithAddress(address), WithPlaintext(), WithShutdownTimeout(5*time.Second))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	dialCtx, dialCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer dialCancel()
+	conn, err := grpc.DialContext(dialCtx, address, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("failed dialing: %s", err)
+	}
+	defer conn.Close()
+
+	result := make(chan error, 1)
+	go func() {
+		out := new(wrapperspb.StringValue)
+		result <- conn.Invoke(context.Background(), "/test.Slow/Wait", &wrapperspb.StringValue{Value: "in flight"}, out)
+	}()
+	select {
+	case <-service.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("call did not start")
+	}
+	cancel()
+
+	if err := <-result; err != nil {
+		t.Errorf("in-flight call failed: %s", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("run failed: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return")
+	}
+}
@@ -0,0 +1,231 @@
+// Package metrics records Prometheus metrics of the REST and gRPC traffic
+// of a grpcgw server.
+//
+// gRPC calls are labeled by their full method name and status code, and by
+// their origin: "grpc" for native calls and "gateway" for calls made by the
+// REST gateway. REST calls are labeled by their route and HTTP status. The
+// route of a REST call that was served by the gateway is the full method
+// name of the RPC it invoked, so the same RPC can be followed on both
+// transports. Recovered panics are counted by transport.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/posener/grpcgw/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	namespace = "grpcgw"
+
+	// OriginGRPC is the origin of native gRPC calls.
+	OriginGRPC = "grpc"
+	// OriginGateway is the origin of gRPC calls made by the REST gateway.
+	OriginGateway = "gateway"
+
+	// unmatchedRoute is the route of REST calls that did not match any
+	// route.
+	unmatchedRoute = "unmatched"
+)
+
+// Metrics records the metrics of a server.
+type Metrics struct {
+	// Origin returns the origin of a gRPC call. If nil, all calls are
+	// native.
+	Origin func(context.Context) string
+
+	registry *prometheus.Registry
+
+	grpcRequests *prometheus.CounterVec
+	grpcDuration *prometheus.HistogramVec
+	grpcInFlight *prometheus.GaugeVec
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	httpInFlight prometheus.Gauge
+
+	panics *prometheus.CounterVec
+}
+
+// New returns metrics registered on a new registry, together with the Go
+// runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		grpcRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "grpc_requests_total",
+			Help:      "Handled gRPC calls.",
+		}, []string{"method", "code", "origin"}),
+		grpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "grpc_request_duration_seconds",
+			Help:      "Duration of handled gRPC calls.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "origin"}),
+		grpcInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "grpc_requests_in_flight",
+			Help:      "gRPC calls being handled.",
+		}, []string{"method", "origin"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Handled REST calls.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of handled REST calls.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		httpInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "REST calls being handled.",
+		}),
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "panics_total",
+			Help:      "Recovered panics of REST handlers and gRPC methods.",
+		}, []string{"transport"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.grpcRequests, m.grpcDuration, m.grpcInFlight,
+		m.httpRequests, m.httpDuration, m.httpInFlight,
+		m.panics,
+	)
+	return m
+}
+
+// Registry returns the registry of the metrics, so more collectors can be
+// registered on it.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Panic counts a recovered panic of a transport, "http" or "grpc". It can
+// be passed to the recovery middleware and interceptors.
+func (m *Metrics) Panic(transport string) {
+	m.panics.WithLabelValues(transport).Inc()
+}
+
+func (m *Metrics) origin(ctx context.Context) string {
+	if m.Origin == nil {
+		return OriginGRPC
+	}
+	return m.Origin(ctx)
+}
+
+// observeGRPC records a gRPC call from its start, and returns a function
+// that records its end.
+func (m *Metrics) observeGRPC(ctx context.Context, method string) func(error) {
+	origin := m.origin(ctx)
+	inFlight := m.grpcInFlight.WithLabelValues(method, origin)
+	inFlight.Inc()
+	start := time.Now()
+	return func(err error) {
+		inFlight.Dec()
+		m.grpcDuration.WithLabelValues(method, origin).Observe(time.Since(start).Seconds())
+		m.grpcRequests.WithLabelValues(method, status.Code(err).String(), origin).Inc()
+	}
+}
+
+// UnaryServerInterceptor records the metrics of unary gRPC calls.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		done := m.observeGRPC(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		done(err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the metrics of streaming gRPC calls.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done := m.observeGRPC(stream.Context(), info.FullMethod)
+		err := handler(srv, stream)
+		done(err)
+		return err
+	}
+}
+
+// Middleware records the metrics of REST calls. The route of a call is
+// set by Route, or by the gateway interceptors for calls served by the
+// REST gateway.
+func (m *Metrics) Middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.httpInFlight.Inc()
+		defer m.httpInFlight.Dec()
+
+		route := &routeLabel{}
+		sw := middleware.NewStatusWriter(w)
+		start := time.Now()
+		handler.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), routeKey{}, route)))
+
+		name := route.name
+		if name == "" {
+			name = unmatchedRoute
+		}
+		m.httpDuration.WithLabelValues(name, r.Method).Observe(time.Since(start).Seconds())
+		m.httpRequests.WithLabelValues(name, r.Method, strconv.Itoa(sw.Status)).Inc()
+	})
+}
+
+type routeKey struct{}
+
+// routeLabel holds the route of a REST call. It is set by inner handlers
+// and read by the middleware once the call is handled.
+type routeLabel struct {
+	name string
+}
+
+// setRoute sets the route of the REST call of ctx, if it is recorded.
+func setRoute(ctx context.Context, name string) {
+	if route, ok := ctx.Value(routeKey{}).(*routeLabel); ok {
+		route.name = name
+	}
+}
+
+// Route labels the REST calls handled by handler with a route pattern.
+func Route(pattern string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setRoute(r.Context(), pattern)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// GatewayUnaryInterceptor labels the REST calls served by the REST
+// gateway with the full method name of the RPC they invoke. It should be
+// installed on the gateway's connection to the gRPC server.
+func GatewayUnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		setRoute(ctx, method)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// GatewayStreamInterceptor is the streaming version of
+// GatewayUnaryInterceptor.
+func GatewayStreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		setRoute(ctx, method)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// BodyLimitOptions configures the maximal size of request bodies.
+type BodyLimitOptions struct {
+	// MaxBytes is the maximal size of request bodies of paths that match
+	// no route. Zero is not limited.
+	MaxBytes int64
+	// Routes are the maximal sizes of request bodies by path patterns,
+	// such as "/v1/upload" or "/v1/files/*". The most specific pattern
+	// that matches a path applies.
+	Routes map[string]int64
+}
+
+// limit returns the maximal body size of a path.
+func (o BodyLimitOptions) limit(path string) int64 {
+	if limit, ok := o.Routes[path]; ok {
+		return limit
+	}
+	best, found := "", false
+	for pattern := range o.Routes {
+		prefix := strings.TrimSuffix(pattern, "*")
+		if prefix != pattern && strings.HasPrefix(path, prefix) && (!found || len(pattern) > len(best)) {
+			best, found = pattern, true
+		}
+	}
+	if found {
+		return o.Routes[best]
+	}
+	return o.MaxBytes
+}
+
+// BodyLimit returns a middleware that limits the size of request bodies.
+// Requests whose Content-Length is too large are rejected with 413
+// Request Entity Too Large, and reading past the limit of other requests,
+// such as chunked ones, fails. Handlers that reply 400 Bad Request to
+// such a failure reply 413 Request Entity Too Large instead.
+func BodyLimit(o BodyLimitOptions) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := o.limit(r.URL.Path)
+			if limit <= 0 {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength > limit {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			body := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit)}
+			r.Body = body
+			handler.ServeHTTP(&bodyLimitWriter{ResponseWriter: w, body: body}, r)
+		})
+	}
+}
+
+// limitedBody is a request body limited by BodyLimit. It records whether
+// reading it failed since it exceeded the limit.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// BodyTooLarge reports whether reading the body of a request failed since
+// it exceeded the limit of BodyLimit.
+func BodyTooLarge(r *http.Request) bool {
+	b, ok := r.Body.(*limitedBody)
+	return ok && b.exceeded
+}
+
+// bodyLimitWriter replies 413 Request Entity Too Large instead of 400 Bad
+// Request to requests whose body exceeded the limit, since handlers such
+// as the REST gateway report any failure to read the body as a bad
+// request.
+type bodyLimitWriter struct {
+	http.ResponseWriter
+	body *limitedBody
+}
+
+func (w *bodyLimitWriter) WriteHeader(statusCode int) {
+	if statusCode == http.StatusBadRequest && w.body.exceeded {
+		statusCode = http.StatusRequestEntityTooLarge
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *bodyLimitWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
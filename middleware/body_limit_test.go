@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimit(t *testing.T) {
+	// The handler replies like the REST gateway: any failure to read the
+	// body is a bad request.
+	handler := BodyLimit(BodyLimitOptions{
+		MaxBytes: 10,
+		Routes:   map[string]int64{"/v1/upload/*": 20, "/v1/upload/small": 5},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("bad") != "" {
+			http.Error(w, "bad", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		size    int
+		chunked bool
+		want    int
+	}{
+		{name: "under the limit", path: "/v1/echo", size: 10, want: http.StatusOK},
+		{name: "over the limit", path: "/v1/echo", size: 11, want: http.StatusRequestEntityTooLarge},
+		{name: "chunked under the limit", path: "/v1/echo", size: 10, chunked: true, want: http.StatusOK},
+		{name: "chunked over the limit", path: "/v1/echo", size: 11, chunked: true, want: http.StatusRequestEntityTooLarge},
+		{name: "route limit", path: "/v1/upload/big", size: 20, want: http.StatusOK},
+		{name: "chunked over the route limit", path: "/v1/upload/big", size: 21, chunked: true, want: http.StatusRequestEntityTooLarge},
+		{name: "most specific route limit", path: "/v1/upload/small", size: 6, chunked: true, want: http.StatusRequestEntityTooLarge},
+		{name: "other bad request", path: "/v1/echo?bad=1", size: 1, chunked: true, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(strings.Repeat("x", tt.size))
+			if tt.chunked {
+				// A reader of unknown length is sent chunked.
+				body = io.MultiReader(body)
+			}
+			req, err := http.NewRequest(http.MethodPost, srv.URL+tt.path, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
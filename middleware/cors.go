@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCORSMethods are the methods allowed for cross-origin requests when
+// no methods are configured.
+var DefaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// CORSOptions configures cross-origin resource sharing.
+type CORSOptions struct {
+	// AllowedOrigins are the origins allowed to make cross-origin requests.
+	// "*" allows every origin, and "https://*.example.com" allows every
+	// subdomain of example.com.
+	AllowedOrigins []string
+	// AllowedMethods bound the methods allowed on every path. If empty,
+	// DefaultCORSMethods are used.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed in cross-origin
+	// requests. "*" allows every header.
+	AllowedHeaders []string
+	// AllowCredentials allows cross-origin requests with cookies and
+	// client certificates.
+	AllowCredentials bool
+	// MaxAge is the time browsers may cache a preflight response.
+	MaxAge time.Duration
+	// Methods returns the methods served on a path. If it is set and
+	// returns methods, only those of them that are in AllowedMethods are
+	// allowed on the path.
+	Methods func(path string) []string
+}
+
+// CORS returns a middleware that answers preflight requests and sets the
+// CORS headers of cross-origin requests from allowed origins.
+func CORS(o CORSOptions) func(http.Handler) http.Handler {
+	if len(o.AllowedMethods) == 0 {
+		o.AllowedMethods = DefaultCORSMethods
+	}
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if !o.allowOrigin(origin) {
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				handler.ServeHTTP(w, r)
+				return
+			}
+			o.setOrigin(w, origin)
+			if !preflight {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			methods := o.methods(r.URL.Path)
+			if !contains(methods, r.Header.Get("Access-Control-Request-Method")) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if headers := o.headers(r.Header.Get("Access-Control-Request-Headers")); headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+			if o.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(o.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// allowOrigin reports whether an origin may make cross-origin requests.
+func (o CORSOptions) allowOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range o.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if i := strings.Index(allowed, "*."); i >= 0 {
+			scheme, domain := allowed[:i], allowed[i+1:]
+			if strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, domain) && len(origin) > len(scheme)+len(domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setOrigin allows the origin of the response. Any origin is allowed with
+// "*", unless credentials are allowed, which requires the explicit origin.
+func (o CORSOptions) setOrigin(w http.ResponseWriter, origin string) {
+	if contains(o.AllowedOrigins, "*") && !o.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if o.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// methods returns the methods allowed on a path.
+func (o CORSOptions) methods(path string) []string {
+	if o.Methods == nil {
+		return o.AllowedMethods
+	}
+	served := o.Methods(path)
+	if len(served) == 0 {
+		return o.AllowedMethods
+	}
+	var methods []string
+	for _, method := range served {
+		if contains(o.AllowedMethods, method) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// headers returns the allowed headers out of the requested headers.
+func (o CORSOptions) headers(requested string) string {
+	var headers []string
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && (contains(o.AllowedHeaders, "*") || contains(o.AllowedHeaders, header)) {
+			headers = append(headers, header)
+		}
+	}
+	return strings.Join(headers, ", ")
+}
+
+// contains reports whether a value is in values, case-insensitively.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import "testing"
+
+func TestCORSAllowOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{name: "none", origin: "https://example.com", want: false},
+		{name: "any", allowed: []string{"*"}, origin: "https://example.com", want: true},
+		{name: "exact", allowed: []string{"https://example.com"}, origin: "https://example.com", want: true},
+		{name: "case", allowed: []string{"https://Example.com"}, origin: "https://EXAMPLE.com", want: true},
+		{name: "other scheme", allowed: []string{"https://example.com"}, origin: "http://example.com", want: false},
+		{name: "subdomain", allowed: []string{"https://*.example.com"}, origin: "https://api.example.com", want: true},
+		{name: "nested subdomain", allowed: []string{"https://*.example.com"}, origin: "https://a.b.example.com", want: true},
+		{name: "wildcard does not match the domain", allowed: []string{"https://*.example.com"}, origin: "https://example.com", want: false},
+		{name: "wildcard suffix", allowed: []string{"https://*.example.com"}, origin: "https://evilexample.com", want: false},
+		{name: "wildcard other domain", allowed: []string{"https://*.example.com"}, origin: "https://api.example.com.evil.io", want: false},
+		{name: "wildcard other scheme", allowed: []string{"https://*.example.com"}, origin: "http://api.example.com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := CORSOptions{AllowedOrigins: tt.allowed}
+			if got := o.allowOrigin(tt.origin); got != tt.want {
+				t.Errorf("allowOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
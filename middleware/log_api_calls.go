@@ -1,19 +1,51 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	"log"
 	"net/http"
+	"strings"
+	"sync"
 )
 
 func APILoggerMiddleware(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logW := newLogResponseWriter(w)
-		handler.ServeHTTP(logW, r)
-		log.Printf("API called: status=%d verb=%-5s length=%-4d path=%s", logW.Status(), r.Method, logW.ContentLength(), r.URL.Path)
+		fields := &logFields{}
+		handler.ServeHTTP(logW, r.WithContext(context.WithValue(r.Context(), logFieldsKey{}, fields)))
+		log.Printf("API called: status=%d verb=%-5s length=%-4d path=%s%s", logW.Status(), r.Method, logW.ContentLength(), r.URL.Path, fields)
 	})
 }
 
+type logFieldsKey struct{}
+
+// logFields are additional fields of an access log line, set by inner
+// handlers.
+type logFields struct {
+	mu     sync.Mutex
+	fields []string
+}
+
+func (f *logFields) String() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.fields) == 0 {
+		return ""
+	}
+	return " " + strings.Join(f.fields, " ")
+}
+
+// SetLogField adds a "name=value" field to the access log line of the
+// REST call of ctx, if it is logged by APILoggerMiddleware.
+func SetLogField(ctx context.Context, name, value string) {
+	if f, ok := ctx.Value(logFieldsKey{}).(*logFields); ok {
+		f.mu.Lock()
+		f.fields = append(f.fields, name+"="+value)
+		f.mu.Unlock()
+	}
+}
+
 // logResponseWriter is a wrapper around ResponseWriter
 // that helps fetching the status code and content length of the response
 // for logging purposes.
@@ -29,7 +61,7 @@ func newLogResponseWriter(w http.ResponseWriter) *logResponseWriter {
 }
 
 func (w *logResponseWriter) Write(data []byte) (int, error) {
-	if w.status == http.StatusInternalServerError {
+	if w.status == http.StatusInternalServerError && w.Header().Get("Content-Type") != ProblemContentType {
 		log.Printf("Inernal server error: %s", data)
 		return 0, errors.New("Internal server error")
 	}
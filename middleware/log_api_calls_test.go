@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPILoggerMiddlewareBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		wantBody    string
+	}{
+		{name: "ok", status: http.StatusOK, contentType: "application/json", wantBody: `{"ok":true}`},
+		{name: "bad request", status: http.StatusBadRequest, contentType: "application/json", wantBody: `{"ok":true}`},
+		{name: "internal error", status: http.StatusInternalServerError, contentType: "application/json", wantBody: ""},
+		{name: "internal error problem", status: http.StatusInternalServerError, contentType: ProblemContentType, wantBody: `{"ok":true}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := APILoggerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"ok":true}`))
+			}))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/test", nil))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
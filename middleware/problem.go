@@ -0,0 +1,6 @@
+package middleware
+
+// ProblemContentType is the content type of RFC 7807 problem details
+// bodies. They are written to the callers even with 500 Internal Server
+// Error, since they are made for callers.
+const ProblemContentType = "application/problem+json"
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Transports of recovered panics.
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
+)
+
+// Recovery returns a middleware that recovers panics of REST handlers,
+// logs them with their stack, and replies with 500 Internal Server Error.
+// onPanic, if not nil, is called with TransportHTTP for every panic.
+func Recovery(onPanic func(transport string)) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					// Aborting a response is not a failure.
+					panic(p)
+				}
+				// The request ID middleware, if it is inner, set the
+				// request ID on the response before the handler panicked.
+				ctx := r.Context()
+				if id := w.Header().Get(RequestIDHeader); id != "" {
+					ctx = NewRequestIDContext(ctx, id)
+				}
+				Logf(ctx, "Recovered panic: path=%s panic=%q stack=%q", r.URL.Path, p, debug.Stack())
+				if onPanic != nil {
+					onPanic(TransportHTTP)
+				}
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// recoverGRPC turns a panic of a gRPC method into an Internal error.
+func recoverGRPC(ctx context.Context, method string, onPanic func(string), err *error) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	Logf(ctx, "Recovered panic: method=%s panic=%q stack=%q", method, p, debug.Stack())
+	if onPanic != nil {
+		onPanic(TransportGRPC)
+	}
+	*err = status.Error(codes.Internal, "internal error")
+}
+
+// RecoveryUnaryServerInterceptor recovers panics of unary gRPC methods,
+// logs them with their stack, and returns an Internal error. onPanic, if
+// not nil, is called with TransportGRPC for every panic.
+func RecoveryUnaryServerInterceptor(onPanic func(transport string)) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (_ interface{}, err error) {
+		defer recoverGRPC(ctx, info.FullMethod, onPanic, &err)
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamServerInterceptor recovers panics of streaming gRPC
+// methods, logs them with their stack, and returns an Internal error.
+// onPanic, if not nil, is called with TransportGRPC for every panic.
+func RecoveryStreamServerInterceptor(onPanic func(transport string)) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer recoverGRPC(stream.Context(), info.FullMethod, onPanic, &err)
+		return handler(srv, stream)
+	}
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// RequestIDHeader is the header of request IDs of REST calls.
+	RequestIDHeader = "X-Request-Id"
+	// RequestIDMetadataKey is the metadata key of request IDs of gRPC
+	// calls. The REST gateway forwards request IDs under this key.
+	RequestIDMetadataKey = "x-request-id"
+
+	// maxRequestIDLength is the maximal length of a request ID sent by a
+	// caller.
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// NewRequestIDContext returns a context carrying a request ID.
+func NewRequestIDContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID of a REST or gRPC call.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// requestID returns the request ID sent by a caller if it is valid, or a
+// new one. A valid request ID is short, and has only letters, digits and
+// "-", "_", ".", ":", so it is safe to log.
+func requestID(sent string) string {
+	if sent != "" && len(sent) <= maxRequestIDLength && strings.Trim(sent, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.:") == "" {
+		return sent
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Panicf("Failed generating request ID: %s", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// RequestID accepts the X-Request-Id header of REST calls, or generates a
+// request ID, and puts it into the request context and into the access
+// log. The request ID is echoed in the response header, and in the
+// response trailer if the caller accepts trailers.
+func RequestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r.Header.Get(RequestIDHeader))
+		ctx := NewRequestIDContext(r.Context(), id)
+		SetLogField(ctx, "request_id", id)
+		w.Header().Set(RequestIDHeader, id)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+		if strings.Contains(strings.ToLower(r.Header.Get("TE")), "trailers") {
+			w.Header().Set(http.TrailerPrefix+RequestIDHeader, id)
+		}
+	})
+}
+
+// outgoingRequestID returns the context of a gRPC call of the REST
+// gateway, with the request ID of the REST call. It replaces any request ID
+// that the gateway forwarded from HTTP headers, since those are not
+// validated.
+func outgoingRequestID(ctx context.Context) context.Context {
+	id, ok := RequestIDFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(RequestIDMetadataKey, id)
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// RequestIDUnaryClientInterceptor forwards the request ID of REST calls on
+// unary calls of the REST gateway, which carry the context of the REST
+// request.
+func RequestIDUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingRequestID(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// RequestIDStreamClientInterceptor forwards the request ID of REST calls
+// on streaming calls of the REST gateway.
+func RequestIDStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingRequestID(ctx), desc, cc, method, opts...)
+	}
+}
+
+// newRequestIDContext accepts the request ID of a gRPC call, or generates
+// one, and echoes it in the response header and trailer.
+func newRequestIDContext(ctx context.Context) context.Context {
+	var sent string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDMetadataKey); len(values) > 0 {
+			sent = values[0]
+		}
+	}
+	id := requestID(sent)
+	SetLogField(ctx, "request_id", id)
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, id))
+	grpc.SetTrailer(ctx, metadata.Pairs(RequestIDMetadataKey, id))
+	return NewRequestIDContext(ctx, id)
+}
+
+// RequestIDUnaryServerInterceptor puts the request ID of unary gRPC calls
+// into their context.
+func RequestIDUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(newRequestIDContext(ctx), req)
+	}
+}
+
+// RequestIDStreamServerInterceptor puts the request ID of streaming gRPC
+// calls into their context.
+func RequestIDStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := newRequestIDContext(stream.Context())
+		return handler(srv, WrapServerStream(stream, ctx))
+	}
+}
+
+// Logf logs a line of a REST or gRPC call, with its request ID.
+func Logf(ctx context.Context, format string, v ...interface{}) {
+	line := fmt.Sprintf(format, v...)
+	if id, ok := RequestIDFromContext(ctx); ok {
+		line += " request_id=" + id
+	}
+	log.Print(line)
+}
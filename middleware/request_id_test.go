@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		sent     string
+		wantSent bool
+	}{
+		{name: "uuid", sent: "3f2a9c1e-7b4d-4e8a-9f00-1c2d3e4f5a6b", wantSent: true},
+		{name: "punctuation", sent: "trace_1.span:2", wantSent: true},
+		{name: "max length", sent: strings.Repeat("a", maxRequestIDLength), wantSent: true},
+		{name: "empty", sent: ""},
+		{name: "too long", sent: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "space", sent: "id with spaces"},
+		{name: "newline", sent: "id\nforged=1"},
+		{name: "quote", sent: `id"`},
+		{name: "non ascii", sent: "идентификатор"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := requestID(tt.sent)
+			if tt.wantSent {
+				if got != tt.sent {
+					t.Errorf("requestID(%q) = %q, want the sent ID", tt.sent, got)
+				}
+				return
+			}
+			if got == tt.sent || len(got) != 32 || strings.Trim(got, "0123456789abcdef") != "" {
+				t.Errorf("requestID(%q) = %q, want a generated ID", tt.sent, got)
+			}
+		})
+	}
+}
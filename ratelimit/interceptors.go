@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// retryAfterKey is the header, and the metadata key, of the time after
+	// which a throttled call may be retried.
+	retryAfterKey = "Retry-After"
+	// forwardedForKey is the metadata key of the REST caller's address,
+	// set by the REST gateway.
+	forwardedForKey = "x-forwarded-for"
+)
+
+// Middleware limits REST calls by their path, except for calls served by
+// the REST gateway, which are limited by the RPC they invoke. Throttled
+// callers get 429 Too Many Requests with a Retry-After header.
+func (l *Limiter) Middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.Routed != nil && l.Routed(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		caller := l.caller(r.Context(), hostIP(r.RemoteAddr))
+		if ok, delay := l.allow(caller, "path", l.config.Paths, r.URL.Path); !ok {
+			w.Header().Set(retryAfterKey, retryAfter(delay))
+			if l.Reject != nil {
+				l.Reject(w, r, throttled(delay))
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// check limits a gRPC call by its full method name. Throttled callers get
+// RESOURCE_EXHAUSTED with a RetryInfo detail and a retry-after header.
+func (l *Limiter) check(ctx context.Context, method string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	caller := l.caller(ctx, l.peerIP(ctx, md))
+	ok, delay := l.allow(caller, "method", l.config.Methods, method)
+	if ok {
+		return nil
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(retryAfterKey, retryAfter(delay)))
+	return throttled(delay)
+}
+
+// throttled returns the RESOURCE_EXHAUSTED error of a throttled call, with
+// a RetryInfo detail.
+func throttled(delay time.Duration) error {
+	st, err := status.New(codes.ResourceExhausted, "rate limit exceeded").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return st.Err()
+}
+
+// UnaryServerInterceptor limits unary gRPC calls.
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor limits streaming gRPC calls when they start.
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.check(stream.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+// peerIP returns the IP address of a gRPC caller. For calls made by the
+// REST gateway, it is the address of the REST caller.
+func (l *Limiter) peerIP(ctx context.Context, md metadata.MD) string {
+	if l.Forwarded != nil && l.Forwarded(ctx) {
+		// The gateway appends the REST caller's address to the forwarded
+		// addresses.
+		if forwarded := md.Get(forwardedForKey); len(forwarded) > 0 {
+			addresses := strings.Split(forwarded[len(forwarded)-1], ",")
+			return strings.TrimSpace(addresses[len(addresses)-1])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		return hostIP(p.Addr.String())
+	}
+	return ""
+}
+
+// retryAfter formats a delay as the whole seconds of a Retry-After header.
+func retryAfter(delay time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(delay.Seconds())))
+}
@@ -0,0 +1,218 @@
+// Package ratelimit limits the rate of REST and gRPC calls to a grpcgw
+// server with token buckets.
+//
+// Every caller has its own buckets, keyed by its IP address, its API key
+// or its authenticated identity. gRPC calls are limited by their full
+// method name, and REST calls by their path, each with the limit of the
+// most specific pattern that matches it. A REST call that is served by the
+// REST gateway is limited only by the RPC it invokes, so it is charged
+// once, and its caller is keyed by the credentials that the RPC
+// authenticated.
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/posener/grpcgw/auth"
+	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
+)
+
+// Keys of the callers' buckets.
+const (
+	// KeyIP keys callers by their IP address.
+	KeyIP = "ip"
+	// KeyAPIKey keys callers by the name of their authenticated API key, or
+	// by their IP address if they were not authenticated by one.
+	KeyAPIKey = "api-key"
+	// KeyIdentity keys callers by their certificate identity or their JWT
+	// subject, or by their IP address if they were not authenticated.
+	KeyIdentity = "identity"
+)
+
+// idleTimeout is the time after which the bucket of an idle caller is
+// dropped.
+const idleTimeout = 10 * time.Minute
+
+// Limit is the rate of a token bucket. A zero rate is not limited.
+type Limit struct {
+	// Rate is the number of calls per second.
+	Rate float64 `json:"rate"`
+	// Burst is the number of calls that may be made at once. It is at
+	// least one.
+	Burst int `json:"burst"`
+}
+
+// Config configures the limits of the calls.
+type Config struct {
+	// Key is KeyIP, KeyAPIKey or KeyIdentity. The default is KeyIP.
+	Key string `json:"key"`
+	// Default is the limit of calls that match no other pattern.
+	Default Limit `json:"default"`
+	// Methods are the limits of gRPC methods, by full method name
+	// patterns, such as "/pkg.Service/Method" or "/pkg.Service/*".
+	Methods map[string]Limit `json:"methods"`
+	// Paths are the limits of REST calls that are not served by the REST
+	// gateway, by path patterns, such as "/swagger-ui/*".
+	Paths map[string]Limit `json:"paths"`
+}
+
+// LoadConfig reads a config from a JSON file, such as:
+//
+//	{
+//		"key": "identity",
+//		"default": {"rate": 10, "burst": 20},
+//		"methods": {"/pkg.Service/Expensive": {"rate": 1, "burst": 1}},
+//		"paths": {"/swagger-ui/*": {"rate": 5, "burst": 10}}
+//	}
+func LoadConfig(path string) (Config, error) {
+	var c Config
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	return c, nil
+}
+
+// Limiter holds the buckets of the callers.
+type Limiter struct {
+	// Forwarded reports whether a gRPC call was made by the REST gateway,
+	// so the IP address it forwarded can be trusted. If nil, forwarded
+	// addresses are ignored.
+	Forwarded func(ctx context.Context) bool
+	// Routed reports whether a REST call is served by the REST gateway.
+	// Such calls are not limited by their path. If nil, every REST call
+	// is limited by its path.
+	Routed func(r *http.Request) bool
+	// Reject replies to a throttled REST call with err, a
+	// RESOURCE_EXHAUSTED status error. If nil, throttled REST calls get a
+	// plain 429 Too Many Requests.
+	Reject func(w http.ResponseWriter, r *http.Request, err error)
+
+	config Config
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	*rate.Limiter
+	lastUsed time.Time
+}
+
+// New returns a limiter of a config.
+func New(c Config) (*Limiter, error) {
+	switch c.Key {
+	case "":
+		c.Key = KeyIP
+	case KeyIP, KeyAPIKey, KeyIdentity:
+	default:
+		return nil, fmt.Errorf("invalid rate limit key %q", c.Key)
+	}
+	return &Limiter{config: c, buckets: map[string]*bucket{}, lastSweep: time.Now()}, nil
+}
+
+// allow takes a token from the bucket of a caller for a gRPC method or a
+// REST path. If the bucket is empty, it returns the time after which the
+// call may be retried.
+func (l *Limiter) allow(caller, kind string, rules map[string]Limit, name string) (bool, time.Duration) {
+	pattern, limit := l.match(rules, name)
+	if limit.Rate <= 0 {
+		return true, 0
+	}
+	now := time.Now()
+	key := kind + " " + pattern + " " + caller
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		burst := limit.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		b = &bucket{Limiter: rate.NewLimiter(rate.Limit(limit.Rate), burst)}
+		l.buckets[key] = b
+	}
+	b.lastUsed = now
+	r := b.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// match returns the most specific pattern that matches a name, and its
+// limit. Names that match no pattern have the default limit.
+func (l *Limiter) match(rules map[string]Limit, name string) (string, Limit) {
+	if limit, ok := rules[name]; ok {
+		return name, limit
+	}
+	best, found := "", false
+	for pattern := range rules {
+		prefix := strings.TrimSuffix(pattern, "*")
+		if prefix != pattern && strings.HasPrefix(name, prefix) && (!found || len(pattern) > len(best)) {
+			best, found = pattern, true
+		}
+	}
+	if found {
+		return best, rules[best]
+	}
+	return "", l.config.Default
+}
+
+// sweep drops the buckets of idle callers. It is called with the lock
+// held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTimeout {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.lastUsed) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// caller returns the bucket key of a caller. Only authenticated
+// credentials key a caller, since unvalidated ones are chosen by the caller.
+func (l *Limiter) caller(ctx context.Context, ip string) string {
+	switch l.config.Key {
+	case KeyAPIKey:
+		if key, ok := auth.APIKeyFromContext(ctx); ok {
+			return "name:" + key.Name
+		}
+	case KeyIdentity:
+		if id, ok := auth.FromContext(ctx); ok {
+			if names := id.Names(); len(names) > 0 {
+				return "id:" + names[0]
+			}
+		}
+		if claims, ok := auth.ClaimsFromContext(ctx); ok && claims.Subject() != "" {
+			return "sub:" + claims.Subject()
+		}
+	}
+	return "ip:" + ip
+}
+
+// hostIP returns the IP address of a "host:port" address.
+func hostIP(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
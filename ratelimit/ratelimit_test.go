@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/posener/grpcgw/auth"
+	"golang.org/x/net/context"
+)
+
+func TestLimiterCaller(t *testing.T) {
+	background := context.Background()
+	withKey := auth.NewAPIKeyContext(background, &auth.APIKey{Name: "partner-a"})
+	withCert := auth.NewContext(background, &auth.Identity{CommonName: "client.example.com", DNSNames: []string{"alt.example.com"}})
+	withClaims := auth.NewClaimsContext(background, auth.Claims{"sub": "alice"})
+	withAll := auth.NewAPIKeyContext(auth.NewClaimsContext(withCert, auth.Claims{"sub": "alice"}), &auth.APIKey{Name: "partner-a"})
+
+	tests := []struct {
+		name string
+		key  string
+		ctx  context.Context
+		want string
+	}{
+		{name: "ip", key: KeyIP, ctx: withAll, want: "ip:10.0.0.1"},
+		{name: "default is ip", ctx: withAll, want: "ip:10.0.0.1"},
+		{name: "api key", key: KeyAPIKey, ctx: withKey, want: "name:partner-a"},
+		{name: "api key of an unauthenticated caller", key: KeyAPIKey, ctx: background, want: "ip:10.0.0.1"},
+		{name: "api key of a caller authenticated otherwise", key: KeyAPIKey, ctx: withClaims, want: "ip:10.0.0.1"},
+		{name: "identity certificate", key: KeyIdentity, ctx: withCert, want: "id:client.example.com"},
+		{name: "identity certificate before jwt", key: KeyIdentity, ctx: withAll, want: "id:client.example.com"},
+		{name: "identity jwt", key: KeyIdentity, ctx: withClaims, want: "sub:alice"},
+		{name: "identity jwt without subject", key: KeyIdentity, ctx: auth.NewClaimsContext(background, auth.Claims{}), want: "ip:10.0.0.1"},
+		{name: "identity of an api key caller", key: KeyIdentity, ctx: withKey, want: "ip:10.0.0.1"},
+		{name: "identity of an unauthenticated caller", key: KeyIdentity, ctx: background, want: "ip:10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := New(Config{Key: tt.key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := l.caller(tt.ctx, "10.0.0.1"); got != tt.want {
+				t.Errorf("caller() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiterMiddleware(t *testing.T) {
+	config := Config{
+		Default: Limit{Rate: 0.001, Burst: 1},
+		Paths:   map[string]Limit{"/swagger-ui/*": {Rate: 0.001, Burst: 2}},
+	}
+	routed := func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/v1/") }
+	reject := func(w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		reject bool
+		// want are the statuses of consecutive calls.
+		want []int
+	}{
+		{name: "default", path: "/healthz", want: []int{200, 429, 429}},
+		{name: "pattern", path: "/swagger-ui/index.html", want: []int{200, 200, 429}},
+		{name: "routed", path: "/v1/echo", want: []int{200, 200, 200}},
+		{name: "reject", path: "/healthz", reject: true, want: []int{200, 418}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := New(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l.Routed = routed
+			if tt.reject {
+				l.Reject = reject
+			}
+			handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			for i, want := range tt.want {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				if rec.Code != want {
+					t.Errorf("call %d status = %d, want %d", i, rec.Code, want)
+				}
+				if throttled := want != http.StatusOK; throttled != (rec.Header().Get("Retry-After") != "") {
+					t.Errorf("call %d Retry-After = %q", i, rec.Header().Get("Retry-After"))
+				}
+			}
+		})
+	}
+}
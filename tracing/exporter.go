@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"golang.org/x/net/context"
+)
+
+// Span exporters.
+const (
+	// ExporterNone disables tracing.
+	ExporterNone = "none"
+	// ExporterStdout writes spans to the standard output.
+	ExporterStdout = "stdout"
+	// ExporterOTLP sends spans to an OTLP collector over gRPC.
+	ExporterOTLP = "otlp"
+)
+
+// Config configures the exporting of spans.
+type Config struct {
+	// Exporter is ExporterNone, ExporterStdout or ExporterOTLP.
+	Exporter string
+	// Endpoint is the "host:port" address of the OTLP collector. If empty,
+	// the OTEL_EXPORTER_OTLP_ENDPOINT environment variable or the default
+	// collector address is used.
+	Endpoint string
+	// Insecure connects to the OTLP collector without TLS.
+	Insecure bool
+	// SampleRatio is the ratio of new traces that are sampled. Traces that
+	// were sampled by the caller are always sampled.
+	SampleRatio float64
+	// ServiceName is the name of the service in the exported spans.
+	ServiceName string
+}
+
+// NewExporter returns the span exporter selected by c.Exporter, or nil if
+// tracing is disabled.
+func NewExporter(ctx context.Context, c Config) (sdktrace.SpanExporter, error) {
+	switch c.Exporter {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		var opts []otlptracegrpc.Option
+		if c.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(c.Endpoint))
+		}
+		if c.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("invalid trace exporter %q", c.Exporter)
+	}
+}
+
+// NewProvider returns a tracer provider that batches the sampled spans to
+// exporter. Tests can pass an in-memory exporter, such as the one of
+// go.opentelemetry.io/otel/sdk/trace/tracetest, and flush the provider
+// before inspecting the spans. The provider should be shut down when the
+// server stops, to export the remaining spans.
+func NewProvider(exporter sdktrace.SpanExporter, c Config) *sdktrace.TracerProvider {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+	}
+	if c.ServiceName != "" {
+		opts = append(opts, sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(c.ServiceName))))
+	}
+	return sdktrace.NewTracerProvider(opts...)
+}
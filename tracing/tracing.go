@@ -0,0 +1,167 @@
+// Package tracing traces the REST and gRPC traffic of a grpcgw server with
+// OpenTelemetry.
+//
+// The W3C trace context of REST calls is extracted from their headers, and
+// the REST gateway propagates it to the gRPC server in the metadata of the
+// calls it makes. gRPC calls get a server span named by their full method
+// name, so a REST call and the RPC it invoked belong to the same trace.
+package tracing
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/posener/grpcgw/middleware"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// instrumentationName is the name of the tracer that creates the spans.
+const instrumentationName = "github.com/posener/grpcgw/tracing"
+
+// Tracing creates the spans of a server.
+type Tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// New returns tracing that creates spans with provider, and propagates
+// the W3C trace context and baggage.
+func New(provider trace.TracerProvider) *Tracing {
+	return &Tracing{
+		tracer:     provider.Tracer(instrumentationName),
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+}
+
+// Middleware creates a server span for REST calls, which is a child of
+// the trace context in the request headers, if there is one.
+func (t *Tracing) Middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := t.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := t.tracer.Start(ctx, "HTTP "+r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		sw := middleware.NewStatusWriter(w)
+		handler.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sw.Status))
+		if sw.Status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.Status))
+		}
+	})
+}
+
+// startRPC starts the server span of a gRPC call, as a child of the trace
+// context in the call's metadata, and returns a function that ends it.
+func (t *Tracing) startRPC(ctx context.Context, method string) (context.Context, func(error)) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = t.propagator.Extract(ctx, metadataCarrier(md))
+	service, name := splitMethod(method)
+	ctx, span := t.tracer.Start(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.RPCSystemGRPC, semconv.RPCService(service), semconv.RPCMethod(name)),
+	)
+	return ctx, func(err error) {
+		code := status.Code(err)
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+		if err != nil {
+			span.SetStatus(codes.Error, status.Convert(err).Message())
+		}
+		span.End()
+	}
+}
+
+// UnaryServerInterceptor creates a server span for unary gRPC calls.
+func (t *Tracing) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, end := t.startRPC(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		end(err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor creates a server span for streaming gRPC calls.
+func (t *Tracing) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, end := t.startRPC(stream.Context(), info.FullMethod)
+		err := handler(srv, middleware.WrapServerStream(stream, ctx))
+		end(err)
+		return err
+	}
+}
+
+// inject adds the trace context of ctx to its outgoing metadata.
+func (t *Tracing) inject(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	t.propagator.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// GatewayUnaryInterceptor propagates the trace context of REST calls to
+// the gRPC calls made by the REST gateway. It should be installed on the
+// gateway's connection to the gRPC server.
+func (t *Tracing) GatewayUnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(t.inject(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// GatewayStreamInterceptor is the streaming version of
+// GatewayUnaryInterceptor.
+func (t *Tracing) GatewayStreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(t.inject(ctx), desc, cc, method, opts...)
+	}
+}
+
+// splitMethod splits a full method name "/<service>/<method>" into its
+// service and method names.
+func splitMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "", fullMethod
+}
+
+// metadataCarrier carries a trace context in gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
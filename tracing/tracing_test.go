@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestGatewayTrace tests that the REST span and the server span of the
+// RPC that the gateway invoked belong to the same trace.
+func TestGatewayTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tr := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(tr.UnaryServerInterceptor()))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(tr.GatewayUnaryInterceptor()),
+	)
+	if err != nil {
+		t.Fatalf("failed dialing: %s", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	handler := tr.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := client.Check(r.Context(), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Errorf("check failed: %s", err)
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	rpc, rest := spans[0], spans[1]
+	if got, want := rpc.Name(), "grpc.health.v1.Health/Check"; got != want {
+		t.Errorf("rpc span name = %q, want %q", got, want)
+	}
+	if got, want := rest.Name(), "HTTP GET"; got != want {
+		t.Errorf("rest span name = %q, want %q", got, want)
+	}
+	for _, span := range spans {
+		if got := span.SpanKind(); got != trace.SpanKindServer {
+			t.Errorf("span %q kind = %v, want server", span.Name(), got)
+		}
+	}
+	if rpc.SpanContext().TraceID() != rest.SpanContext().TraceID() {
+		t.Errorf("rpc trace %s, rest trace %s, want the same", rpc.SpanContext().TraceID(), rest.SpanContext().TraceID())
+	}
+	if rpc.Parent().SpanID() != rest.SpanContext().SpanID() {
+		t.Errorf("rpc parent span %s, want the rest span %s", rpc.Parent().SpanID(), rest.SpanContext().SpanID())
+	}
+}